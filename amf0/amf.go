@@ -1,11 +1,17 @@
 package amf0
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
 	"math"
+	"time"
+	"unicode/utf8"
+
+	"github.com/balazshorvath/goamf/amf3"
 )
 
 // Spec @ https://www.adobe.com/content/dam/acom/en/devnet/pdf/amf0-file-format-specification.pdf
@@ -23,175 +29,913 @@ const (
 	ECMAArray            = 0x08 // 4 bytes as assoc count, string keys -> need more info
 	ObjectEnd            = 0x09 // 0x00, 0x00, 0x09 show the end of na object. This is not a regular type it is preceded by the 'Object' type. At least in theory.
 	StrictArray          = 0x0A // 4 bytes for length
-	Date                 = 0x0B // 2 bytes unsigned time zone, but it's not supported and should be set to 0, followed by 8 bytes of double timestamp of millis
+	Date                 = 0x0B // 2 bytes signed time zone in minutes, reserved and should be 0, a nonzero one is parsed into a FixedZone. Followed by 8 bytes of double timestamp of millis
 	LongString           = 0x0C // 4 bytes for length, UTF-8
-	Unsupported          = 0x0D // Error may be appropriate
+	Unsupported          = 0x0D // No content, the value could not be represented
 	Recordset            = 0x0E // Reserved, not supported
 	XmlDocument          = 0x0F // 4 bytes for length and UTF-8
 	TypedObject          = 0x10 // 2 bytes for length of the 'class name', UTF-8, -> then object
-	AvmPlusObject        = 0x11 // The marker indicated, that the following object is AMF3 encoded
+	AvmPlusObject        = 0x11 // The marker indicated, that the following object is AMF3 encoded, see the amf3 package
 )
 
 // Value represents an AMF value with a type, a value and optionally a name.
-// A TypedObject's name is it's class name.
-// ECMAArrays and Objects have named properties.
-// Reference types are already resolved, there are no such types to be found in this tree.
+// The name is the property name of the values in Objects, ECMAArrays and TypedObjects.
+// A TypedObject has it's class name in ClassName.
+// Reference types are already resolved, there are no such types to be found in this tree. A resolved Reference
+// is a new *Value with the content of the container, so it has it's own Name, a container containing itself
+// results in a cycle.
+// Properties with the same name are kept in order and the encoder writes all of them. Property and SetProperty
+// use the first one, AsMap returns an error for them, ToNative and Unmarshal keep the last one.
 type Value struct {
+	Marker    Marker
+	Name      string
+	ClassName string
+	Value     interface{}
+	// Count is the associative count declared by an ECMAArray, the encoder writes the actual count.
+	Count uint32
+	// ByteLength is the amount of bytes the value was read from, including the marker, but not the property name.
+	// For a resolved Reference it's the length of the reference. The encoder ignores it.
+	ByteLength int
+	// The Date as it was read, for bit-exact encoding
+	date *wireDate
+	// The byte of a Boolean as it was read, see Encoder.PreserveBooleans
+	boolean byte
+	// The container a Reference was resolved to, the encoder writes a Reference to it for the value
+	ref *Value
+}
+
+// wireDate is the Date as it's encoded.
+type wireDate struct {
+	millis   float64
+	timezone int16
+}
+
+const (
+	DefaultMaxDepth        = 512
+	DefaultMaxBytes        = 64 << 20
+	DefaultMaxStringLength = 16 << 20
+	DefaultMaxReferences   = 4096
+)
+
+// maxInternedNames is the most names kept by a parser with InternNames, the rest are not interned.
+const maxInternedNames = 4096
+
+// maxPreallocation is the largest buffer allocated before reading the data.
+const maxPreallocation = 64 << 10
+
+var (
+	ErrMaxDepthExceeded      = errors.New("maximum depth exceeded")
+	ErrMaxBytesExceeded      = errors.New("maximum bytes exceeded")
+	ErrMaxReferencesExceeded = errors.New("maximum references exceeded")
+	ErrUnsupportedMarker     = errors.New("unsupported marker")
+	ErrInvalidReference      = errors.New("invalid reference")
+)
+
+// UnsupportedMarkerError is returned for valid, but not supported markers.
+// It matches ErrUnsupportedMarker with errors.Is.
+type UnsupportedMarkerError struct {
 	Marker Marker
-	Name   string
-	Value  interface{}
 }
 
+func (e *UnsupportedMarkerError) Error() string {
+	return fmt.Sprintf("unsupported marker %v", e.Marker)
+}
+
+func (e *UnsupportedMarkerError) Unwrap() error {
+	return ErrUnsupportedMarker
+}
+
+// InvalidReferenceError is returned for a Reference with an index out of the reference table,
+// which has Count values. It matches ErrInvalidReference with errors.Is.
+type InvalidReferenceError struct {
+	Index int
+	Count int
+}
+
+func (e *InvalidReferenceError) Error() string {
+	return fmt.Sprintf("reference index %d is out of the reference table of %d values", e.Index, e.Count)
+}
+
+func (e *InvalidReferenceError) Unwrap() error {
+	return ErrInvalidReference
+}
+
+// Parser reads AMF0 values from the reader.
+// MaxDepth limits the nesting of objects and arrays, MaxBytes limits the amount of bytes read by a single Parse,
+// MaxStringLength limits the length of a single string, MaxReferences limits the containers added to the reference
+// table by a single Parse, values less than 1 disable the limit. MaxTotalStringBytes limits the sum of the lengths
+// of the strings read by a single Parse, including the property and class names, it's disabled by default.
+// The reference table is kept between the values until Reset, so a long-lived parser should be reset
+// where the references of the stream end. It keeps the first 65536 containers, the ones a Reference can point to,
+// the later containers are counted, but not kept.
+//
+// By default the parser accepts the real-world, but technically broken streams, except for an invalid
+// ECMAArray associative count. The checks can be enabled one by one, or all of them with Strict:
+//   - Lenient disables the validation of the ECMAArray associative count, unless Strict is set.
+//   - StrictUTF8 makes strings with invalid UTF-8 an error.
+//   - StrictTimezone makes Dates with a nonzero time zone an error, the spec reserves the field.
+//   - StrictClassName makes TypedObjects with an empty class name an error,
+//     otherwise they are kept as TypedObjects with an empty ClassName.
+//   - StrictFinite makes NaN and infinite Numbers and Dates an error.
+//
+// RawProperty selects the properties kept as RawValue at any depth, see RawValue.
+//
+// CountedECMAArrays reads the ECMAArrays with a nonzero associative count as that many properties,
+// if there is no 'ObjectEnd' after them. It's for compatibility with the encoders, which don't terminate
+// ECMAArrays, and a warning is reported for them.
+//
+// InternNames makes the property and class names read share their strings, which saves the allocations
+// for many objects of the same properties. The names are kept until Reset, up to a few thousand.
+//
+// ReadTimeout sets the read deadline of a reader with a SetReadDeadline method, like a net.Conn, before every read,
+// so a stalled connection makes Parse fail with the timeout error of the reader, which is a net.Error for a net.Conn.
+// The deadline is left set after Parse. With ParseContext, a cancellation may wait for the timeout of a read.
+//
+// Recover makes Parse drop the rest of a container with a corrupt property or element, see RecoveredError.
+//
+// LittleEndian reads the lengths, counts, references, time zones and doubles as little-endian, which is not AMF0.
+// It's only meant for checking, whether a broken capture is byte-swapped. AvmPlusObjects are not affected.
+//
+// The unknown markers are read as values without content, keeping their marker, Strict makes them
+// an UnsupportedMarkerError. TolerateUnknownMarkers reads them as Unsupported values instead.
+//
+// OnWarning is called with the offset of the value for the anomalies tolerated by the enabled checks,
+// like a nonzero time zone, and for the unknown markers.
 type Parser struct {
-	reader     io.Reader
+	MaxDepth               int
+	MaxBytes               int
+	MaxStringLength        int
+	MaxReferences          int
+	MaxTotalStringBytes    int
+	Strict                 bool
+	Lenient                bool
+	StrictUTF8             bool
+	StrictTimezone         bool
+	StrictClassName        bool
+	StrictFinite           bool
+	OnWarning              func(offset int, warning string)
+	RawProperty            func(name string) bool
+	Recover                bool
+	LittleEndian           bool
+	CountedECMAArrays      bool
+	InternNames            bool
+	ReadTimeout            time.Duration
+	TolerateUnknownMarkers bool
+
+	// Counts the bytes read, the AMF3 parser reads through it too
+	reader     countingReader
 	references []*Value
-	bytesRead  int
+	// The containers added to the reference table, including the ones not kept past math.MaxUint16
+	referenceCount int
+	// Offset of the value being parsed, an EOF is only expected at the start
+	valueStart int
+	depth      int
+	// Size of the reference table at the start of the value, for MaxReferences
+	referencesStart int
+	// The containers being parsed and the References read to them, which are completed at the end of the container
+	open    []*Value
+	pending []*Value
+	// The lengths of the strings read by this Parse, for MaxTotalStringBytes
+	stringBytes int
+	// Marker of the value being parsed, for the error messages
+	marker Marker
+	// The offset the last read running out of data needed to reach, for the IncrementalParser
+	readEnd int
+	// Used for the fixed size reads to avoid allocations
+	scratch [8]byte
+	// AMF3 has it's own reference tables, they are kept for every AvmPlusObject read by this parser
+	amf3 *amf3.Parser
+	// Set with RegisterMarker
+	markers map[Marker]func(p *Parser, v *Value) error
+	// Set during ParseContext
+	ctx context.Context
+	// Set during ParseFiltered
+	keep func(name string) bool
+	// The names interned with InternNames
+	names map[string]string
+	// Set with OnTypedObject, OnDate and OnECMAArray
+	typedObjectHooks map[string]func(v *Value)
+	dateHook         func(v *Value)
+	ecmaArrayHook    func(v *Value)
 }
 
 func New(reader io.Reader) *Parser {
-	return &Parser{
-		reader: reader,
+	p := &Parser{
+		MaxDepth:        DefaultMaxDepth,
+		MaxBytes:        DefaultMaxBytes,
+		MaxStringLength: DefaultMaxStringLength,
+		MaxReferences:   DefaultMaxReferences,
 	}
+	p.reader.reset(reader)
+	return p
 }
 
-func (p *Parser) Parse() (value *Value, bytesRead int, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			e, ok := r.(error)
-			if !ok {
-				err = errors.New(fmt.Sprintf("%v", r))
-			} else {
-				err = e
+// Parse reads a single value from the data with a new parser, returning the value and the amount of bytes read.
+func Parse(data []byte) (*Value, int, error) {
+	return New(bytes.NewReader(data)).Parse()
+}
+
+// Reset clears the state of the parser and continues with the reader, the options are kept.
+// This allows reusing the parser for independent streams.
+func (p *Parser) Reset(reader io.Reader) {
+	p.reader.reset(reader)
+	p.clearReferences()
+	p.valueStart = 0
+	p.depth = 0
+	p.stringBytes = 0
+	p.names = nil
+}
+
+// clearReferences empties the AMF0 and AMF3 reference tables.
+func (p *Parser) clearReferences() {
+	for i := range p.references {
+		p.references[i] = nil
+	}
+	p.references = p.references[:0]
+	p.referenceCount = 0
+	p.referencesStart = 0
+	if p.amf3 != nil {
+		p.amf3.Reset(&p.reader)
+	}
+}
+
+// WithReferences replaces the reference table, so the References of the next values can point to refs,
+// like to the values read by another parser. The values read are added after them. Returns the parser.
+func (p *Parser) WithReferences(refs []*Value) *Parser {
+	p.references = append([]*Value(nil), refs...)
+	p.referenceCount = len(refs)
+	return p
+}
+
+// References returns a copy of the reference table, the Objects, ECMAArrays, TypedObjects and StrictArrays
+// in the order they were started. The containers not kept by the parser, like the skipped ones, are nil.
+// Only the first 65536 containers are in the table.
+func (p *Parser) References() []*Value {
+	return append([]*Value(nil), p.references...)
+}
+
+// SeekTo moves the reader to the offset from the start of the reader, if it implements io.Seeker.
+// The next Parse starts reading from there, the bytes read count is set to the offset.
+// The reference tables are kept.
+func (p *Parser) SeekTo(offset int64) error {
+	if err := p.reader.seek(offset); err != nil {
+		return err
+	}
+	p.valueStart = p.reader.count
+	p.depth = 0
+	return nil
+}
+
+// Position returns the offset of the next read. If the reader was not at it's start when the parser
+// got it and SeekTo was not called, it's relative to that.
+func (p *Parser) Position() int64 {
+	return int64(p.reader.count)
+}
+
+// Parse reads the next value. The returned bytesRead is the total amount of bytes read by the parser.
+// If the reader had no more values, the error is io.EOF, a value ending early results in io.ErrUnexpectedEOF.
+func (p *Parser) Parse() (*Value, int, error) {
+	p.startValue()
+	value, err := p.parseNext()
+	if err != nil {
+		return nil, p.reader.count, fmt.Errorf("offset %d: %w", p.reader.count, err)
+	}
+	return value, p.reader.count, nil
+}
+
+// ParseAll reads values until the end of the reader.
+func (p *Parser) ParseAll() ([]*Value, int, error) {
+	var values []*Value
+	for {
+		value, bytesRead, err := p.Parse()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return values, bytesRead, nil
 			}
+			return values, bytesRead, err
 		}
-	}()
+		values = append(values, value)
+	}
+}
+
+// startValue sets the state for reading a top level value.
+func (p *Parser) startValue() {
+	p.valueStart = p.reader.count
+	p.referencesStart = p.referenceCount
+	p.open = p.open[:0]
+	p.pending = p.pending[:0]
+	p.reader.timeout = p.ReadTimeout
+	p.stringBytes = 0
+}
+
+// parseNext reads a marker and the value following it.
+func (p *Parser) parseNext() (*Value, error) {
+	value := &Value{}
+	if err := p.parseNextInto(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
 
-	data := p.readBytes(p.reader, 1)
-	marker := Marker(data[0])
-	value = &Value{
-		Marker: marker,
+// parseNextInto reads a marker and the value following it into the value.
+func (p *Parser) parseNextInto(value *Value) error {
+	marker, err := p.readByte()
+	if err != nil {
+		return err
 	}
-	p.parseValue(value)
-	return value, p.bytesRead, nil
+	value.Marker = Marker(marker)
+	return p.parseValue(value)
 }
 
-func (p *Parser) parseValue(value *Value) {
+func (p *Parser) parseValue(value *Value) error {
+	p.depth++
+	previous := p.marker
+	p.marker = value.Marker
+	// The marker is already read
+	start := p.reader.count - 1
+	defer func() {
+		p.depth--
+		p.marker = previous
+		value.ByteLength = p.reader.count - start
+	}()
+	if p.MaxDepth > 0 && p.depth > p.MaxDepth {
+		return fmt.Errorf("%w: %d", ErrMaxDepthExceeded, p.MaxDepth)
+	}
+	if err := p.checkContext(); err != nil {
+		return err
+	}
 	switch value.Marker {
 	case Number:
-		value.Value = p.readDouble()
+		number, err := p.readDouble()
+		if err != nil {
+			return err
+		}
+		value.Value = number
 	case Boolean:
-		data := p.readBytes(p.reader, 1)
-		value.Value = data[0] != 0
+		b, err := p.readByte()
+		if err != nil {
+			return err
+		}
+		value.Value = b != 0
+		value.boolean = b
 	case LongString, XmlDocument, String:
-		str, _ := p.readString(value.Marker)
+		str, _, err := p.readString(value.Marker)
+		if err != nil {
+			return err
+		}
 		value.Value = str
 	case Object:
-		value.Value = p.parseProperties()
-		p.references = append(p.references, value)
-	case Null, Undefined:
+		// References are counted in the order the objects start
+		if err := p.addReference(value); err != nil {
+			return err
+		}
+		properties, _, err := p.parseProperties(-1)
+		if err != nil {
+			return err
+		}
+		value.Value = properties
+		p.closeContainer(value)
+	case Null, Undefined, Unsupported:
 		value.Value = nil
 	case Reference:
-		data := p.readBytes(p.reader, 2)
-		index := binary.BigEndian.Uint16(data)
-		if int(index) > len(p.references)-1 {
-			panic("reference index is greater, than the amount of available reference objects")
+		index, err := p.readReferenceIndex()
+		if err != nil {
+			return err
 		}
 		ref := p.references[index]
+		if ref == nil {
+			return errors.New("reference to a value, that was not kept by the parser")
+		}
+		// The marker tells an ECMAArray from an Object, the Count is kept for it too
 		value.Value = ref.Value
 		value.Marker = ref.Marker
+		value.ClassName = ref.ClassName
+		value.Count = ref.Count
+		value.ref = ref
+		// The content of a container is set at it's end
+		for _, open := range p.open {
+			if open == ref {
+				p.pending = append(p.pending, value)
+				break
+			}
+		}
 	case ECMAArray:
-		// Length ignored, because assoc arrays should have 'ObjectEnd'
-		_ = p.readBytes(p.reader, 4)
-		properties := p.parseProperties()
+		// Assoc arrays should have 'ObjectEnd', the count is validated against the parsed properties
+		data, err := p.readBytes(4)
+		if err != nil {
+			return err
+		}
+		value.Count = p.byteOrder().Uint32(data)
+		if err := p.addReference(value); err != nil {
+			return err
+		}
+		properties, count, err := p.parseProperties(p.countedLength(value.Count))
+		if err != nil {
+			return err
+		}
+		if int(value.Count) != count && !isRecovered(properties) {
+			if p.checkCount() {
+				return fmt.Errorf("ecma array declared %d properties, but has %d", value.Count, count)
+			}
+			p.warn(start, "ecma array declared %d properties, but has %d", value.Count, count)
+		}
 		value.Value = properties
-		p.references = append(p.references, value)
+		p.closeContainer(value)
+		p.decoded(value, start)
 	case StrictArray:
 		// Length
-		data := p.readBytes(p.reader, 4)
-		length := int(binary.BigEndian.Uint32(data))
-		// Marker
-		data = p.readBytes(p.reader, 1)
-		arrayMarker := Marker(data[0])
-		// Collect
-		var values []*Value
+		data, err := p.readBytes(4)
+		if err != nil {
+			return err
+		}
+		length := int(p.byteOrder().Uint32(data))
+		if err := p.checkArrayLength(length); err != nil {
+			return err
+		}
+		if err := p.addReference(value); err != nil {
+			return err
+		}
+		// Collect, every element has it's own marker, an empty array is only the length and a non-nil slice
+		values := make([]*Value, 0, minInt(length, maxPreallocation/8))
 		for i := 0; i < length; i++ {
-			arrayValue := &Value{
-				Marker: arrayMarker,
+			// There's no terminator to find after a corrupt element, so it's left to the enclosing object
+			arrayValue, err := p.parseNext()
+			if err != nil {
+				return err
 			}
-			p.parseValue(arrayValue)
 			values = append(values, arrayValue)
 		}
 		value.Value = values
+		p.closeContainer(value)
 	case Date:
-		// not supported
-		_ = p.readBytes(p.reader, 2)
-		data := p.readBytes(p.reader, 8)
-		value.Value = math.Float64frombits(binary.BigEndian.Uint64(data))
+		// Time zone is reserved and should be 0, but some encoders write the offset in minutes
+		data, err := p.readBytes(2)
+		if err != nil {
+			return err
+		}
+		timezone := int16(p.byteOrder().Uint16(data))
+		if timezone != 0 {
+			if p.Strict || p.StrictTimezone {
+				return fmt.Errorf("date has a nonzero time zone %d", timezone)
+			}
+			p.warn(start, "date has a nonzero time zone %d", timezone)
+		}
+		millis, err := p.readDouble()
+		if err != nil {
+			return err
+		}
+		t := millisToTime(millis)
+		if timezone != 0 {
+			t = t.In(time.FixedZone("", int(timezone)*60))
+		}
+		value.Value = t
+		value.date = &wireDate{
+			millis:   millis,
+			timezone: timezone,
+		}
+		p.decoded(value, start)
 	case TypedObject:
-		// Class name
-		name, _ := p.readString(String)
-		value.Name = name
+		// Class name, the property terminator can not be mistaken for it, since the object can't end before it
+		className, _, err := p.readName()
+		if err != nil {
+			return err
+		}
+		if className == "" {
+			if p.Strict || p.StrictClassName {
+				return errors.New("typed object has an empty class name")
+			}
+			p.warn(start, "typed object has an empty class name")
+		}
+		value.ClassName = className
+		if err := p.addReference(value); err != nil {
+			return err
+		}
 		// Props
-		value.Value = p.parseProperties()
-		p.references = append(p.references, value)
+		properties, _, err := p.parseProperties(-1)
+		if err != nil {
+			return err
+		}
+		value.Value = properties
+		p.closeContainer(value)
+		p.decoded(value, start)
 	case AvmPlusObject:
-		panic("amf3 is not supported")
-	case Unsupported, Recordset, Movieclip:
-		panic(fmt.Sprintf("unsupported type %d", value.Marker))
+		// The value is stored as an *amf3.Value. Properties and array elements are parsed here as well,
+		// so the AMF3 reference tables are shared by the AvmPlusObjects at any depth.
+		amf3Value, err := p.parseAMF3()
+		if err != nil {
+			return err
+		}
+		value.Value = amf3Value
+	case ObjectEnd:
+		return errUnexpectedObjectEnd
+	case Recordset, Movieclip:
+		if fn, ok := p.markers[value.Marker]; ok {
+			return fn(p, value)
+		}
+		return &UnsupportedMarkerError{Marker: value.Marker}
 	default:
+		if fn, ok := p.markers[value.Marker]; ok {
+			return fn(p, value)
+		}
+		if p.Strict {
+			return &UnsupportedMarkerError{Marker: value.Marker}
+		}
+		p.warn(start, "unknown marker 0x%02x", byte(value.Marker))
+		if p.TolerateUnknownMarkers {
+			value.Marker = Unsupported
+		}
 	}
+	return nil
 }
 
-func (p *Parser) parseProperties() []*Value {
+// parseProperties reads the properties until 'ObjectEnd', or until limit properties if it's not negative,
+// see countedLength. The returned count includes the properties skipped by the filter of ParseFiltered.
+func (p *Parser) parseProperties(limit int) ([]*Value, int, error) {
 	var properties []*Value
+	count := 0
 	for {
-		name, nameLength := p.readString(String)
+		if count == limit {
+			if err := p.readCountedEnd(count); err != nil {
+				return nil, count, err
+			}
+			break
+		}
+		name, nameLength, err := p.readName()
+		if err != nil {
+			return nil, count, unterminatedError(err)
+		}
 		// Check if 'ObjectEnd'
 		if nameLength == 0 {
-			data := p.readBytes(p.reader, 1)
+			offset := p.reader.count
+			marker, err := p.readByte()
+			if err != nil {
+				return nil, count, unterminatedError(err)
+			}
 			// Should be always this way
-			if data[0] == ObjectEnd {
-				break
-			} else {
-				panic("i'm stupid and i fucked up")
+			if marker != ObjectEnd {
+				return p.recoverProperties(properties, count, "", offset, objectEndError(marker))
+			}
+			break
+		}
+		offset := p.reader.count
+		marker, err := p.readByte()
+		if err != nil {
+			return nil, count, err
+		}
+		count++
+		if p.RawProperty != nil && p.RawProperty(name) {
+			property, err := p.readRaw(Marker(marker), name)
+			if err != nil {
+				return nil, count, err
+			}
+			properties = append(properties, property)
+			continue
+		}
+		// The filter only applies to the properties of the top level value
+		if p.keep != nil && p.depth == 1 && !p.keep(name) {
+			if err := p.skipValue(Marker(marker)); err != nil {
+				return nil, count, err
 			}
+			continue
 		}
-		data := p.readBytes(p.reader, 1)
-		marker := Marker(data[0])
 		property := &Value{
-			Marker: marker,
+			Marker: Marker(marker),
 			Name:   name,
 		}
-		p.parseValue(property)
+		if err := p.parseValue(property); err != nil {
+			return p.recoverProperties(properties, count, name, offset, err)
+		}
 		properties = append(properties, property)
 	}
-	return properties
+	return properties, count, nil
+}
+
+// countedLength returns the amount of properties of an ECMAArray with the count, which are read
+// without an 'ObjectEnd', -1 if it has to be terminated.
+func (p *Parser) countedLength(count uint32) int {
+	if !p.CountedECMAArrays || count == 0 || count > math.MaxInt32 {
+		return -1
+	}
+	return int(count)
+}
+
+// readCountedEnd reads the 'ObjectEnd' after the count properties of an ECMAArray, if it's there.
+func (p *Parser) readCountedEnd(count int) error {
+	offset := p.reader.count
+	data := p.scratch[:3]
+	n, err := io.ReadFull(&p.reader, data)
+	if n == 3 && data[0] == 0 && data[1] == 0 && data[2] == ObjectEnd {
+		return nil
+	}
+	if err == io.ErrUnexpectedEOF && bytes.HasPrefix([]byte{0, 0, ObjectEnd}, data[:n]) {
+		// Either the 'ObjectEnd' or the next value ends early
+		return err
+	}
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	// It's the next value
+	p.reader.unread(data[:n])
+	p.warn(offset, "ecma array has no 'ObjectEnd' after %d properties", count)
+	return nil
+}
+
+// unterminatedError adds the context to the data ending before the 'ObjectEnd' of an object.
+func unterminatedError(err error) error {
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("object not terminated by 'ObjectEnd': %w", err)
+	}
+	return err
+}
+
+// errUnexpectedObjectEnd is returned for an 'ObjectEnd' read as a value, outside of the properties.
+var errUnexpectedObjectEnd = errors.New("unexpected 'ObjectEnd' marker, it can only terminate the properties of an object")
+
+func objectEndError(marker byte) error {
+	return fmt.Errorf("object not terminated: expected 'ObjectEnd' after an empty property name, got marker %v", Marker(marker))
+}
+
+func (p *Parser) parseAMF3() (*amf3.Value, error) {
+	if p.amf3 == nil {
+		p.amf3 = amf3.New(&p.reader)
+	}
+	// The limits are shared with the AMF3 parser
+	p.amf3.MaxDepth = 0
+	if p.MaxDepth > 0 {
+		p.amf3.MaxDepth = p.MaxDepth - p.depth + 1
+	}
+	p.amf3.MaxBytes = 0
+	if p.MaxBytes > 0 {
+		// A zero limit would disable it
+		p.amf3.MaxBytes = p.MaxBytes - p.bytesInValue()
+		if p.amf3.MaxBytes < 1 {
+			return nil, fmt.Errorf("%w: no bytes left for the amf3 value, limit is %d", ErrMaxBytesExceeded, p.MaxBytes)
+		}
+	}
+	value, _, err := p.amf3.Parse()
+	if errors.Is(err, io.EOF) {
+		// The AvmPlusObject marker is already read
+		return nil, fmt.Errorf("%v: %w", err, io.ErrUnexpectedEOF)
+	} else if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// checkArrayLength fails fast for StrictArrays longer, than the bytes left, every element is at least 1 byte.
+func (p *Parser) checkArrayLength(length int) error {
+	if p.MaxBytes > 0 && length > p.MaxBytes-p.bytesInValue() {
+		return fmt.Errorf("%w: strict array of length %d does not fit into %d bytes", ErrMaxBytesExceeded, length, p.MaxBytes)
+	}
+	if remaining, ok := p.reader.remaining(); ok && length > remaining {
+		return fmt.Errorf("strict array of length %d does not fit into the remaining %d bytes: %w", length, remaining, io.ErrUnexpectedEOF)
+	}
+	return nil
 }
 
-func (p *Parser) readDouble() float64 {
-	data := p.readBytes(p.reader, 8)
-	return math.Float64frombits(binary.BigEndian.Uint64(data))
+func (p *Parser) byteOrder() binary.ByteOrder {
+	if p.LittleEndian {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// warn reports the tolerated anomaly to OnWarning.
+func (p *Parser) warn(offset int, format string, args ...interface{}) {
+	if p.OnWarning != nil {
+		p.OnWarning(offset, fmt.Sprintf(format, args...))
+	}
+}
+
+// checkCount reports whether the ECMAArray associative count should be validated.
+func (p *Parser) checkCount() bool {
+	return p.Strict || !p.Lenient
+}
+
+// readReferenceIndex reads the index of a Reference, which has to be in the reference table.
+func (p *Parser) readReferenceIndex() (int, error) {
+	data, err := p.readBytes(2)
+	if err != nil {
+		return 0, err
+	}
+	index := int(p.byteOrder().Uint16(data))
+	if index >= len(p.references) {
+		return 0, &InvalidReferenceError{Index: index, Count: len(p.references)}
+	}
+	return index, nil
+}
+
+// addReference adds the value to the reference table. A container is open until closeContainer.
+// The containers past the largest index of a Reference are only counted.
+func (p *Parser) addReference(value *Value) error {
+	if p.MaxReferences > 0 && p.referenceCount-p.referencesStart >= p.MaxReferences {
+		return fmt.Errorf("%w: %d", ErrMaxReferencesExceeded, p.MaxReferences)
+	}
+	p.referenceCount++
+	if len(p.references) > math.MaxUint16 {
+		return nil
+	}
+	p.references = append(p.references, value)
+	if value != nil {
+		p.open = append(p.open, value)
+	}
+	return nil
 }
 
-func (p *Parser) readString(marker Marker) (string, int) {
-	var nameLength int32
+// closeContainer completes the values read by a Reference to the container, while it was parsed.
+func (p *Parser) closeContainer(container *Value) {
+	for i := len(p.open) - 1; i >= 0; i-- {
+		if p.open[i] == container {
+			p.open = p.open[:i]
+			break
+		}
+	}
+	kept := p.pending[:0]
+	for _, value := range p.pending {
+		if value.ref == container {
+			value.Value = container.Value
+			value.Count = container.Count
+		} else {
+			kept = append(kept, value)
+		}
+	}
+	p.pending = kept
+}
+
+func (p *Parser) readDouble() (float64, error) {
+	data, err := p.readBytes(8)
+	if err != nil {
+		return 0, err
+	}
+	number := math.Float64frombits(p.byteOrder().Uint64(data))
+	if (p.Strict || p.StrictFinite) && (math.IsNaN(number) || math.IsInf(number, 0)) {
+		return 0, fmt.Errorf("non-finite double %v", number)
+	}
+	return number, nil
+}
+
+func (p *Parser) readString(marker Marker) (string, int, error) {
+	data, nameLength, err := p.readStringData(marker)
+	if err != nil {
+		return "", 0, err
+	}
+	return string(data), nameLength, nil
+}
+
+// readName reads a property or class name, interning it if InternNames is set.
+func (p *Parser) readName() (string, int, error) {
+	data, nameLength, err := p.readStringData(String)
+	if err != nil || !p.InternNames {
+		return string(data), nameLength, err
+	}
+	// The conversion of the key doesn't allocate
+	if name, ok := p.names[string(data)]; ok {
+		return name, nameLength, nil
+	}
+	name := string(data)
+	if len(p.names) < maxInternedNames {
+		if p.names == nil {
+			p.names = make(map[string]string)
+		}
+		p.names[name] = name
+	}
+	return name, nameLength, nil
+}
+
+// readStringData reads the bytes of a string, the slice is only valid until the next read.
+func (p *Parser) readStringData(marker Marker) ([]byte, int, error) {
+	nameLength, err := p.readStringLength(marker)
+	if err != nil {
+		return nil, 0, err
+	}
+	if p.MaxTotalStringBytes > 0 {
+		if nameLength > p.MaxTotalStringBytes-p.stringBytes {
+			return nil, 0, fmt.Errorf("string of length %d exceeds the limit %d of the strings, %d bytes were read",
+				nameLength, p.MaxTotalStringBytes, p.stringBytes)
+		}
+		p.stringBytes += nameLength
+	}
+	data, err := p.readBytes(nameLength)
+	if err != nil {
+		return nil, 0, err
+	}
+	if (p.Strict || p.StrictUTF8 || p.OnWarning != nil) && !utf8.Valid(data) {
+		if p.Strict || p.StrictUTF8 {
+			return nil, 0, errors.New("invalid UTF-8 string")
+		}
+		p.warn(p.reader.count-len(data), "invalid UTF-8 string")
+	}
+	return data, nameLength, nil
+}
+
+// readStringLength reads the 2 bytes length of a String or the 4 bytes length of a LongString or XmlDocument.
+func (p *Parser) readStringLength(marker Marker) (int, error) {
+	var nameLength uint32
 	if marker == String {
-		data := p.readBytes(p.reader, 2)
-		nameLength = int32(binary.BigEndian.Uint16(data))
+		data, err := p.readBytes(2)
+		if err != nil {
+			return 0, err
+		}
+		nameLength = uint32(p.byteOrder().Uint16(data))
 	} else if marker == LongString || marker == XmlDocument {
-		data := p.readBytes(p.reader, 4)
-		nameLength = int32(binary.BigEndian.Uint32(data))
+		data, err := p.readBytes(4)
+		if err != nil {
+			return 0, err
+		}
+		nameLength = p.byteOrder().Uint32(data)
 	}
-	data := p.readBytes(p.reader, int(nameLength))
-	return string(data), int(nameLength)
+	if p.MaxStringLength > 0 && uint64(nameLength) > uint64(p.MaxStringLength) {
+		return 0, fmt.Errorf("string length %d exceeds the limit %d", nameLength, p.MaxStringLength)
+	}
+	if uint64(nameLength) > math.MaxInt32 {
+		return 0, fmt.Errorf("string length %d is too large", nameLength)
+	}
+	return int(nameLength), nil
 }
 
-func (p *Parser) readBytes(reader io.Reader, length int) []byte {
-	buffer := make([]byte, length)
-	n, err := io.ReadFull(reader, buffer)
-	p.bytesRead += n
+// readBytes reads exactly length bytes. Reads up to 8 bytes use the scratch buffer of the parser,
+// the returned slice is only valid until the next read.
+func (p *Parser) readBytes(length int) ([]byte, error) {
+	if err := p.checkMaxBytes(length); err != nil {
+		return nil, err
+	}
+	start := p.reader.count
+	if length > maxPreallocation {
+		// The length comes from the input, so the buffer grows with the data actually read
+		var buffer bytes.Buffer
+		_, err := io.CopyN(&buffer, &p.reader, int64(length))
+		if err != nil {
+			p.readEnd = start + length
+			return nil, p.readError(err, length)
+		}
+		return buffer.Bytes(), nil
+	}
+	var buffer []byte
+	if length <= len(p.scratch) {
+		buffer = p.scratch[:length]
+	} else {
+		buffer = make([]byte, length)
+	}
+	_, err := io.ReadFull(&p.reader, buffer)
 	if err != nil {
-		panic(err)
+		p.readEnd = start + length
+		return nil, p.readError(err, length)
 	}
-	return buffer
+	return buffer, nil
+}
+
+// readByte reads a single byte, with ReadByte if the reader supports it.
+func (p *Parser) readByte() (byte, error) {
+	if err := p.checkMaxBytes(1); err != nil {
+		return 0, err
+	}
+	b, err := p.reader.ReadByte()
+	if err != nil {
+		return 0, p.readError(err, 1)
+	}
+	return b, nil
+}
+
+func (p *Parser) checkMaxBytes(length int) error {
+	if p.MaxBytes > 0 && length > p.MaxBytes-p.bytesInValue() {
+		return fmt.Errorf("%w: reading %d bytes, limit is %d", ErrMaxBytesExceeded, length, p.MaxBytes)
+	}
+	return nil
+}
+
+// bytesInValue returns the amount of bytes read since the start of the value, for MaxBytes.
+func (p *Parser) bytesInValue() int {
+	return p.reader.count - p.valueStart
+}
+
+// readError adds the context to an error of a read, the offset is added by the caller of the parser.
+func (p *Parser) readError(err error, length int) error {
+	if err == io.EOF && p.reader.count > p.valueStart {
+		err = io.ErrUnexpectedEOF
+	}
+	if p.depth == 0 {
+		return fmt.Errorf("%w reading marker", err)
+	}
+	return fmt.Errorf("%w reading %d bytes of marker %v", err, length, p.marker)
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// millisToTime converts the milliseconds since epoch to a UTC time.Time.
+func millisToTime(millis float64) time.Time {
+	sec := math.Floor(millis / 1000)
+	nsec := (millis - sec*1000) * float64(time.Millisecond)
+	return time.Unix(int64(sec), int64(nsec)).UTC()
 }
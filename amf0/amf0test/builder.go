@@ -0,0 +1,146 @@
+// Package amf0test has helpers for testing code reading AMF0.
+package amf0test
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/balazshorvath/goamf/amf0"
+)
+
+// Builder assembles AMF0 bytes, every method appends to the data and returns the builder, like
+//
+//	data := amf0test.New().Number(3.14).String("x").Object(func(b *amf0test.Builder) {
+//		b.Name("id").Number(1)
+//	}).Bytes()
+//
+// The lengths and counts are written as they are given, so broken data can be built as well.
+type Builder struct {
+	data []byte
+}
+
+func New() *Builder {
+	return &Builder{}
+}
+
+// Bytes returns the data built.
+func (b *Builder) Bytes() []byte {
+	return b.data
+}
+
+// Raw appends the bytes.
+func (b *Builder) Raw(data ...byte) *Builder {
+	b.data = append(b.data, data...)
+	return b
+}
+
+// Marker appends a marker without content.
+func (b *Builder) Marker(marker amf0.Marker) *Builder {
+	return b.Raw(byte(marker))
+}
+
+// Uint16 appends the big-endian integer.
+func (b *Builder) Uint16(value uint16) *Builder {
+	var data [2]byte
+	binary.BigEndian.PutUint16(data[:], value)
+	return b.Raw(data[:]...)
+}
+
+// Uint32 appends the big-endian integer.
+func (b *Builder) Uint32(value uint32) *Builder {
+	var data [4]byte
+	binary.BigEndian.PutUint32(data[:], value)
+	return b.Raw(data[:]...)
+}
+
+// Double appends the big-endian IEEE-754 double.
+func (b *Builder) Double(value float64) *Builder {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], math.Float64bits(value))
+	return b.Raw(data[:]...)
+}
+
+func (b *Builder) Number(value float64) *Builder {
+	return b.Marker(amf0.Number).Double(value)
+}
+
+func (b *Builder) Boolean(value bool) *Builder {
+	if value {
+		return b.Marker(amf0.Boolean).Raw(1)
+	}
+	return b.Marker(amf0.Boolean).Raw(0)
+}
+
+// String appends a String, the length is truncated to 2 bytes.
+func (b *Builder) String(str string) *Builder {
+	return b.Marker(amf0.String).Uint16(uint16(len(str))).Raw([]byte(str)...)
+}
+
+func (b *Builder) LongString(str string) *Builder {
+	return b.Marker(amf0.LongString).Uint32(uint32(len(str))).Raw([]byte(str)...)
+}
+
+func (b *Builder) XmlDocument(str string) *Builder {
+	return b.Marker(amf0.XmlDocument).Uint32(uint32(len(str))).Raw([]byte(str)...)
+}
+
+func (b *Builder) Null() *Builder {
+	return b.Marker(amf0.Null)
+}
+
+func (b *Builder) Undefined() *Builder {
+	return b.Marker(amf0.Undefined)
+}
+
+func (b *Builder) Unsupported() *Builder {
+	return b.Marker(amf0.Unsupported)
+}
+
+// Reference appends a Reference to the index of the reference table.
+func (b *Builder) Reference(index uint16) *Builder {
+	return b.Marker(amf0.Reference).Uint16(index)
+}
+
+// Date appends a Date with the milliseconds since epoch and the time zone, which should be 0.
+func (b *Builder) Date(millis float64, timezone int16) *Builder {
+	return b.Marker(amf0.Date).Uint16(uint16(timezone)).Double(millis)
+}
+
+// Name appends a property name, the length is truncated to 2 bytes.
+func (b *Builder) Name(name string) *Builder {
+	return b.Uint16(uint16(len(name))).Raw([]byte(name)...)
+}
+
+// End appends the empty property name and the 'ObjectEnd' terminating the properties.
+func (b *Builder) End() *Builder {
+	return b.Raw(0, 0, amf0.ObjectEnd)
+}
+
+// Object appends an Object with the properties appended by fn, terminated by End.
+func (b *Builder) Object(fn func(b *Builder)) *Builder {
+	b.Marker(amf0.Object)
+	fn(b)
+	return b.End()
+}
+
+// ECMAArray appends an ECMAArray with the associative count and the properties appended by fn,
+// terminated by End.
+func (b *Builder) ECMAArray(count uint32, fn func(b *Builder)) *Builder {
+	b.Marker(amf0.ECMAArray).Uint32(count)
+	fn(b)
+	return b.End()
+}
+
+// TypedObject appends a TypedObject with the class name and the properties appended by fn, terminated by End.
+func (b *Builder) TypedObject(className string, fn func(b *Builder)) *Builder {
+	b.Marker(amf0.TypedObject).Name(className)
+	fn(b)
+	return b.End()
+}
+
+// StrictArray appends a StrictArray with the length and the elements appended by fn.
+func (b *Builder) StrictArray(length uint32, fn func(b *Builder)) *Builder {
+	b.Marker(amf0.StrictArray).Uint32(length)
+	fn(b)
+	return b
+}
@@ -0,0 +1,51 @@
+package amf0test_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+// The builder and the encoder have to agree on the encoding of the values.
+func TestBuilderMatchesEncoder(t *testing.T) {
+	shared := amf0.NewObject()
+	tests := []struct {
+		name    string
+		builder *amf0test.Builder
+		value   *amf0.Value
+	}{
+		{"Number", amf0test.New().Number(3.14), amf0.NewNumber(3.14)},
+		{"Boolean", amf0test.New().Boolean(true), amf0.NewBool(true)},
+		{"String", amf0test.New().String("foo"), amf0.NewString("foo")},
+		{"LongString", amf0test.New().LongString("foo"), &amf0.Value{Marker: amf0.LongString, Value: "foo"}},
+		{"XmlDocument", amf0test.New().XmlDocument("<a/>"), &amf0.Value{Marker: amf0.XmlDocument, Value: "<a/>"}},
+		{"Null", amf0test.New().Null(), amf0.NewNull()},
+		{"Undefined", amf0test.New().Undefined(), &amf0.Value{Marker: amf0.Undefined}},
+		{"Date", amf0test.New().Date(1577836800000, 0), amf0.NewDate(time.Unix(1577836800, 0))},
+		{"Object", amf0test.New().Object(func(b *amf0test.Builder) {
+			b.Name("a").Number(1)
+		}), amf0.NewObject(&amf0.Value{Marker: amf0.Number, Name: "a", Value: 1.0})},
+		{"ECMAArray", amf0test.New().ECMAArray(1, func(b *amf0test.Builder) {
+			b.Name("a").Null()
+		}), amf0.NewECMAArray(map[string]*amf0.Value{"a": amf0.NewNull()}, nil)},
+		{"TypedObject", amf0test.New().TypedObject("Point", func(b *amf0test.Builder) {}),
+			&amf0.Value{Marker: amf0.TypedObject, ClassName: "Point"}},
+		{"StrictArray with a Reference", amf0test.New().StrictArray(2, func(b *amf0test.Builder) {
+			b.Object(func(b *amf0test.Builder) {}).Reference(1)
+		}), amf0.NewStrictArray(shared, shared)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := amf0.NewEncoder(&buffer).Encode(test.value); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if !bytes.Equal(test.builder.Bytes(), buffer.Bytes()) {
+				t.Fatalf("built % x, encoded % x", test.builder.Bytes(), buffer.Bytes())
+			}
+		})
+	}
+}
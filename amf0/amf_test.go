@@ -0,0 +1,346 @@
+package amf0_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+	"github.com/balazshorvath/goamf/amf3"
+)
+
+func TestParseAllLimitsPerValue(t *testing.T) {
+	b := amf0test.New()
+	for i := 0; i < 5000; i++ {
+		b.Object(func(b *amf0test.Builder) {
+			b.Name("a").Number(1)
+		})
+	}
+	p := amf0.New(bytes.NewReader(b.Bytes()))
+	// A value is 16 bytes with a single reference
+	p.MaxBytes = 16
+	p.MaxReferences = 1
+	values, _, err := p.ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll failed: %v", err)
+	}
+	if len(values) != 5000 {
+		t.Fatalf("got %d values, expected 5000", len(values))
+	}
+}
+
+func TestParseReferenceTableSize(t *testing.T) {
+	b := amf0test.New()
+	for i := 0; i < 70000; i++ {
+		b.Object(func(b *amf0test.Builder) {})
+	}
+	// A Reference can point to the last Object kept
+	data := b.Reference(65535).Bytes()
+	p := amf0.New(bytes.NewReader(data))
+	values, _, err := p.ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll failed: %v", err)
+	}
+	if len(values) != 70001 || values[70000].Marker != amf0.Object {
+		t.Fatalf("got %d values, expected the Objects and the Reference", len(values))
+	}
+	if references := p.References(); len(references) != 65536 {
+		t.Fatalf("the reference table has %d values, expected the 65536 a Reference can point to", len(references))
+	}
+}
+
+func TestParseLimitsExceeded(t *testing.T) {
+	data := amf0test.New().StrictArray(2, func(b *amf0test.Builder) {
+		b.Object(func(b *amf0test.Builder) {}).Object(func(b *amf0test.Builder) {})
+	}).Bytes()
+	tests := []struct {
+		name  string
+		setup func(p *amf0.Parser)
+		err   error
+	}{
+		{"bytes", func(p *amf0.Parser) { p.MaxBytes = len(data) - 1 }, amf0.ErrMaxBytesExceeded},
+		{"references", func(p *amf0.Parser) { p.MaxReferences = 2 }, amf0.ErrMaxReferencesExceeded},
+		{"depth", func(p *amf0.Parser) { p.MaxDepth = 1 }, amf0.ErrMaxDepthExceeded},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := amf0.New(bytes.NewReader(data))
+			test.setup(p)
+			if _, _, err := p.Parse(); !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, expected %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestParseErrorOffset(t *testing.T) {
+	// The Number ends after 4 of it's 8 bytes
+	data := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("n").Number(1)
+	}).Bytes()[:12]
+	_, _, err := amf0.Parse(data)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("got error %v, expected io.ErrUnexpectedEOF", err)
+	}
+	if expected := "offset 12: unexpected EOF reading 8 bytes of marker Number"; err.Error() != expected {
+		t.Fatalf("got error %q, expected %q", err, expected)
+	}
+}
+
+func TestParseStrictArrayMixed(t *testing.T) {
+	data := amf0test.New().StrictArray(3, func(b *amf0test.Builder) {
+		b.Number(1).String("foo").Boolean(true)
+	}).Bytes()
+	value, bytesRead, err := amf0.Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if bytesRead != len(data) {
+		t.Fatalf("read %d bytes, expected %d", bytesRead, len(data))
+	}
+	expected := amf0.NewStrictArray(amf0.NewNumber(1), amf0.NewString("foo"), amf0.NewBool(true))
+	if !value.Equal(expected) {
+		t.Fatalf("got %v, expected %v", value, expected)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	value, _, err := amf0.Parse(amf0test.New().Date(1577836800123, 0).Bytes())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	expected := time.Date(2020, time.January, 1, 0, 0, 0, 123*int(time.Millisecond), time.UTC)
+	if got, ok := value.Value.(time.Time); !ok || !got.Equal(expected) || got.Location() != time.UTC {
+		t.Fatalf("got %v, expected %v", value.Value, expected)
+	}
+}
+
+func TestParseUnterminatedObject(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		eof  bool
+	}{
+		{"truncated before 'ObjectEnd'", amf0test.New().Marker(amf0.Object).Name("a").Number(1).Bytes(), true},
+		{"truncated in 'ObjectEnd'", amf0test.New().Marker(amf0.Object).Name("a").Number(1).Raw(0, 0).Bytes(), true},
+		{"empty name without 'ObjectEnd'", amf0test.New().Marker(amf0.Object).Name("").Number(1).Bytes(), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := amf0.Parse(test.data)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) != test.eof {
+				t.Fatalf("got error %v, io.ErrUnexpectedEOF expected: %v", err, test.eof)
+			}
+			if !strings.Contains(err.Error(), "not terminated") {
+				t.Fatalf("got error %v, expected it to tell the object is not terminated", err)
+			}
+		})
+	}
+}
+
+func TestParseReferenceToECMAArray(t *testing.T) {
+	data := amf0test.New().StrictArray(2, func(b *amf0test.Builder) {
+		b.ECMAArray(1, func(b *amf0test.Builder) {
+			b.Name("a").Number(1)
+		}).Reference(1)
+	}).Bytes()
+	value, _, err := amf0.Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	ref := value.Value.([]*amf0.Value)[1]
+	if ref.Marker != amf0.ECMAArray || ref.Count != 1 {
+		t.Fatalf("got %v with the count %d, expected an ECMAArray with the count 1", ref.Marker, ref.Count)
+	}
+	if property, ok := ref.Property("a"); !ok || property.Value != 1.0 {
+		t.Fatalf("got %v, expected the properties of the ECMAArray", ref)
+	}
+}
+
+func TestParseEmptyStrictArray(t *testing.T) {
+	// The Number after the array is not read as an element
+	data := amf0test.New().StrictArray(0, func(b *amf0test.Builder) {}).Number(1).Bytes()
+	value, bytesRead, err := amf0.Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if bytesRead != 5 {
+		t.Fatalf("read %d bytes, expected 5", bytesRead)
+	}
+	if elements, ok := value.Value.([]*amf0.Value); !ok || elements == nil || len(elements) != 0 {
+		t.Fatalf("got %#v, expected an empty slice", value.Value)
+	}
+}
+
+func TestParseTypedObjectEmptyClassName(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		properties int
+	}{
+		// The empty class name is not taken for the empty name of an 'ObjectEnd'
+		{"with properties", amf0test.New().TypedObject("", func(b *amf0test.Builder) {
+			b.Name("a").Number(1)
+		}).Bytes(), 1},
+		{"without properties", amf0test.New().TypedObject("", func(b *amf0test.Builder) {}).Bytes(), 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := amf0.New(bytes.NewReader(test.data))
+			var warnings []string
+			p.OnWarning = func(offset int, warning string) {
+				warnings = append(warnings, warning)
+			}
+			value, bytesRead, err := p.Parse()
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if bytesRead != len(test.data) {
+				t.Fatalf("read %d bytes, expected %d", bytesRead, len(test.data))
+			}
+			properties, ok := value.Value.([]*amf0.Value)
+			if value.Marker != amf0.TypedObject || value.ClassName != "" || !ok || len(properties) != test.properties {
+				t.Fatalf("got %v, expected a TypedObject with an empty class name and %d properties", value, test.properties)
+			}
+			if len(warnings) != 1 || warnings[0] != "typed object has an empty class name" {
+				t.Fatalf("got warnings %q, expected one for the empty class name", warnings)
+			}
+		})
+	}
+}
+
+func TestParseStrictClassName(t *testing.T) {
+	data := amf0test.New().TypedObject("", func(b *amf0test.Builder) {
+		b.Name("a").Number(1)
+	}).Bytes()
+	tests := []struct {
+		name  string
+		setup func(p *amf0.Parser)
+	}{
+		{"StrictClassName", func(p *amf0.Parser) { p.StrictClassName = true }},
+		{"Strict", func(p *amf0.Parser) { p.Strict = true }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := amf0.New(bytes.NewReader(data))
+			test.setup(p)
+			_, _, err := p.Parse()
+			if err == nil || !strings.Contains(err.Error(), "empty class name") {
+				t.Fatalf("got error %v, expected one for the empty class name", err)
+			}
+		})
+	}
+	p := amf0.New(bytes.NewReader(amf0test.New().TypedObject("Foo", func(b *amf0test.Builder) {}).Bytes()))
+	p.StrictClassName = true
+	if value, _, err := p.Parse(); err != nil || value.ClassName != "Foo" {
+		t.Fatalf("got %v, %v, expected the TypedObject of the class Foo", value, err)
+	}
+}
+
+func TestParseCountedECMAArrays(t *testing.T) {
+	counted := func(b *amf0test.Builder) *amf0test.Builder {
+		return b.Marker(amf0.ECMAArray).Uint32(1).Name("a").Number(1)
+	}
+	tests := []struct {
+		name     string
+		data     []byte
+		warnings int
+		next     bool
+	}{
+		{"terminated", amf0test.New().ECMAArray(1, func(b *amf0test.Builder) {
+			b.Name("a").Number(1)
+		}).Number(2).Bytes(), 0, true},
+		// The bytes read for the 'ObjectEnd' are the start of the next value
+		{"followed by a value", counted(amf0test.New()).Number(2).Bytes(), 1, true},
+		{"at the end", counted(amf0test.New()).Bytes(), 1, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := amf0.New(bytes.NewReader(test.data))
+			p.CountedECMAArrays = true
+			var warnings []string
+			p.OnWarning = func(offset int, warning string) {
+				warnings = append(warnings, warning)
+			}
+			value, _, err := p.Parse()
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if property, ok := value.Property("a"); value.Marker != amf0.ECMAArray || !ok || property.Value != 1.0 {
+				t.Fatalf("got %v, expected the ECMAArray with the property", value)
+			}
+			if len(warnings) != test.warnings {
+				t.Fatalf("got warnings %q, expected %d", warnings, test.warnings)
+			}
+			next, bytesRead, err := p.Parse()
+			if !test.next {
+				if !errors.Is(err, io.EOF) {
+					t.Fatalf("got %v, %v, expected io.EOF", next, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if next.Value != 2.0 || bytesRead != len(test.data) {
+				t.Fatalf("got %v reading %d bytes, expected the Number after the ECMAArray at %d", next, bytesRead, len(test.data))
+			}
+		})
+	}
+}
+
+func TestParseCountedECMAArrayTruncated(t *testing.T) {
+	// The data ends in the 'ObjectEnd' or in the value after the ECMAArray, which can't be told apart
+	for _, trailer := range [][]byte{{0}, {0, 0}} {
+		data := amf0test.New().Marker(amf0.ECMAArray).Uint32(1).Name("a").Number(1).Raw(trailer...).Bytes()
+		p := amf0.New(bytes.NewReader(data))
+		p.CountedECMAArrays = true
+		if _, _, err := p.Parse(); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("got error %v for the trailer % x, expected io.ErrUnexpectedEOF", err, trailer)
+		}
+	}
+}
+
+func TestParseNestedAvmPlusObjects(t *testing.T) {
+	// The AMF3 array references the string and the object of the AvmPlusObjects of the properties
+	data := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("s").Marker(amf0.AvmPlusObject).Raw(amf3.String, 0x07, 'f', 'o', 'o')
+		b.Name("o").Marker(amf0.AvmPlusObject).Raw(amf3.Object, 0x0B, 0x01, 0x03, 'a', amf3.Integer, 0x01, 0x01)
+		b.Name("list").StrictArray(1, func(b *amf0test.Builder) {
+			b.Marker(amf0.AvmPlusObject).Raw(amf3.Array, 0x05, 0x01, amf3.String, 0x00, amf3.Object, 0x00)
+		})
+	}).Bytes()
+	value, bytesRead, err := amf0.Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if bytesRead != len(data) {
+		t.Fatalf("read %d bytes, expected %d", bytesRead, len(data))
+	}
+	list, _ := value.Property("list")
+	array, ok := list.Value.([]*amf0.Value)[0].Value.(*amf3.Value)
+	if !ok {
+		t.Fatalf("got %v, expected the AMF3 array", list)
+	}
+	encoded, err := array.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	if expected := `["foo",{"a":1}]`; string(encoded) != expected {
+		t.Fatalf("got %s, expected %s", encoded, expected)
+	}
+	var buffer bytes.Buffer
+	if err := amf0.NewEncoder(&buffer).Encode(value); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !bytes.Equal(buffer.Bytes(), data) {
+		t.Fatalf("encoded % x, expected the references kept % x", buffer.Bytes(), data)
+	}
+}
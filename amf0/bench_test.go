@@ -0,0 +1,105 @@
+package amf0_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+// benchmarkParse parses the data repeatedly with a parser reset for every value.
+func benchmarkParse(b *testing.B, data []byte) {
+	reader := bytes.NewReader(data)
+	p := amf0.New(reader)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		reader.Reset(data)
+		p.Reset(reader)
+		if _, _, err := p.Parse(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseNumber(b *testing.B) {
+	benchmarkParse(b, amf0test.New().Number(3.14).Bytes())
+}
+
+func BenchmarkParseString(b *testing.B) {
+	benchmarkParse(b, amf0test.New().String("onMetaData").Bytes())
+}
+
+// metadata is an onMetaData like ECMAArray.
+func metadata() []byte {
+	return amf0test.New().ECMAArray(6, func(b *amf0test.Builder) {
+		b.Name("duration").Number(120.5).
+			Name("width").Number(1920).
+			Name("height").Number(1080).
+			Name("framerate").Number(30).
+			Name("encoder").String("Lavf58.29.100").
+			Name("stereo").Boolean(true)
+	}).Bytes()
+}
+
+func BenchmarkParseECMAArray(b *testing.B) {
+	benchmarkParse(b, metadata())
+}
+
+// The names are interned until Reset, so the parser is only moved back to the start
+func BenchmarkParseECMAArrayInternNames(b *testing.B) {
+	data := metadata()
+	p := amf0.New(bytes.NewReader(data))
+	p.InternNames = true
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if err := p.SeekTo(0); err != nil {
+			b.Fatal(err)
+		}
+		if _, _, err := p.Parse(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseStrictArray(b *testing.B) {
+	data := amf0test.New().StrictArray(100, func(b *amf0test.Builder) {
+		for i := 0; i < 100; i++ {
+			b.Object(func(b *amf0test.Builder) {
+				b.Name("id").Number(float64(i)).Name("name").String("item")
+			})
+		}
+	}).Bytes()
+	benchmarkParse(b, data)
+}
+
+func BenchmarkSkip(b *testing.B) {
+	data := metadata()
+	reader := bytes.NewReader(data)
+	p := amf0.New(reader)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		reader.Reset(data)
+		p.Reset(reader)
+		if _, err := p.Skip(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEncodeECMAArray(b *testing.B) {
+	value, _, err := amf0.Parse(metadata())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := amf0.NewEncoder(io.Discard).Encode(value); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package amf0
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Command is an RTMP command message, like connect, createStream or publish.
+// CommandObject is usually an Object or Null, it's nil if the message ends before it.
+// Args are the optional arguments after it.
+type Command struct {
+	Name          string
+	TransactionID float64
+	CommandObject *Value
+	Args          []*Value
+}
+
+// ParseCommand parses the values of a command message with a single parser, so the references are shared
+// by the values. The name has to be a String or LongString and the transaction id a Number.
+func ParseCommand(data []byte) (*Command, error) {
+	values, _, err := New(bytes.NewReader(data)).ParseAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) < 2 {
+		return nil, fmt.Errorf("command has %d values, expected the name and the transaction id", len(values))
+	}
+	command := &Command{}
+	var ok bool
+	if command.Name, ok = values[0].StringValue(); !ok {
+		return nil, fmt.Errorf("command name is a %v, expected a String", values[0].Marker)
+	}
+	if command.TransactionID, ok = values[1].Float64(); !ok {
+		return nil, fmt.Errorf("command %q: transaction id is a %v, expected a Number", command.Name, values[1].Marker)
+	}
+	if len(values) > 2 {
+		command.CommandObject = values[2]
+		command.Args = values[3:]
+	}
+	return command, nil
+}
@@ -0,0 +1,59 @@
+package amf0
+
+import (
+	"context"
+	"time"
+)
+
+// deadliner is implemented by readers like net.Conn, a pending read can be unblocked with a deadline.
+type deadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// ParseContext is like Parse, but stops with the error of the context, when it's done.
+// The context is checked before every value. If the reader has a SetReadDeadline method, like a net.Conn,
+// a pending read is unblocked by setting the deadline to the past, which is cleared afterwards.
+// Other readers are not interrupted while reading.
+func (p *Parser) ParseContext(ctx context.Context) (*Value, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, p.reader.count, err
+	}
+	p.ctx = ctx
+	defer func() {
+		p.ctx = nil
+	}()
+	if d, ok := p.reader.reader.(deadliner); ok && ctx.Done() != nil {
+		stop := make(chan struct{})
+		stopped := make(chan struct{})
+		interrupted := false
+		go func() {
+			defer close(stopped)
+			select {
+			case <-ctx.Done():
+				interrupted = true
+				_ = d.SetReadDeadline(time.Unix(1, 0))
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-stopped
+			if interrupted {
+				_ = d.SetReadDeadline(time.Time{})
+			}
+		}()
+	}
+	value, bytesRead, err := p.Parse()
+	if err != nil && ctx.Err() != nil {
+		return nil, bytesRead, ctx.Err()
+	}
+	return value, bytesRead, err
+}
+
+// checkContext returns the error of the context set by ParseContext.
+func (p *Parser) checkContext() error {
+	if p.ctx == nil {
+		return nil
+	}
+	return p.ctx.Err()
+}
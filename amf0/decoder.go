@@ -0,0 +1,193 @@
+package amf0
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Handler receives the values read by a Decoder.
+// The name is the property name for the properties of objects and empty otherwise.
+type Handler interface {
+	// OnValue is called for the scalar values. References are not resolved,
+	// the value of a Reference is the uint16 index in the order of the object and array starts.
+	OnValue(marker Marker, name string, value interface{})
+	// OnObjectStart is called for Objects, ECMAArrays and TypedObjects, className is only set for TypedObjects.
+	// The properties follow until OnObjectEnd.
+	OnObjectStart(marker Marker, name string, className string)
+	OnObjectEnd()
+	// OnArrayStart is called for StrictArrays, the elements follow until OnArrayEnd.
+	OnArrayStart(name string, length int)
+	OnArrayEnd()
+}
+
+// Decoder reads values with the parser, reporting them to the handler as they are read,
+// without building the Value tree in memory. The reference table of the parser only gets
+// placeholders, so references read by a later Parse call to these values fail.
+type Decoder struct {
+	parser  *Parser
+	handler Handler
+}
+
+func NewDecoder(parser *Parser, handler Handler) *Decoder {
+	return &Decoder{
+		parser:  parser,
+		handler: handler,
+	}
+}
+
+// Decode reads the next value. The returned bytesRead is the total amount of bytes read by the parser.
+// If the reader had no more values, the error is io.EOF, a value ending early results in io.ErrUnexpectedEOF.
+func (d *Decoder) Decode() (int, error) {
+	p := d.parser
+	p.startValue()
+	marker, err := p.readByte()
+	if err == nil {
+		err = d.decodeValue(Marker(marker), "")
+	}
+	if err != nil {
+		return p.reader.count, fmt.Errorf("offset %d: %w", p.reader.count, err)
+	}
+	return p.reader.count, nil
+}
+
+func (d *Decoder) decodeValue(marker Marker, name string) error {
+	p := d.parser
+	p.depth++
+	previous := p.marker
+	p.marker = marker
+	defer func() {
+		p.depth--
+		p.marker = previous
+	}()
+	if p.MaxDepth > 0 && p.depth > p.MaxDepth {
+		return fmt.Errorf("%w: %d", ErrMaxDepthExceeded, p.MaxDepth)
+	}
+	switch marker {
+	case Object:
+		if err := p.addReference(nil); err != nil {
+			return err
+		}
+		d.handler.OnObjectStart(marker, name, "")
+		if _, err := d.decodeProperties(-1); err != nil {
+			return err
+		}
+		d.handler.OnObjectEnd()
+	case ECMAArray:
+		// The marker is already read
+		start := p.reader.count - 1
+		data, err := p.readBytes(4)
+		if err != nil {
+			return err
+		}
+		count := p.byteOrder().Uint32(data)
+		if err := p.addReference(nil); err != nil {
+			return err
+		}
+		d.handler.OnObjectStart(marker, name, "")
+		length, err := d.decodeProperties(p.countedLength(count))
+		if err != nil {
+			return err
+		}
+		if int(count) != length {
+			if p.checkCount() {
+				return fmt.Errorf("ecma array declared %d properties, but has %d", count, length)
+			}
+			p.warn(start, "ecma array declared %d properties, but has %d", count, length)
+		}
+		d.handler.OnObjectEnd()
+	case TypedObject:
+		// The marker is already read
+		start := p.reader.count - 1
+		className, _, err := p.readName()
+		if err != nil {
+			return err
+		}
+		if className == "" {
+			if p.Strict || p.StrictClassName {
+				return errors.New("typed object has an empty class name")
+			}
+			p.warn(start, "typed object has an empty class name")
+		}
+		if err := p.addReference(nil); err != nil {
+			return err
+		}
+		d.handler.OnObjectStart(marker, name, className)
+		if _, err := d.decodeProperties(-1); err != nil {
+			return err
+		}
+		d.handler.OnObjectEnd()
+	case StrictArray:
+		data, err := p.readBytes(4)
+		if err != nil {
+			return err
+		}
+		length := int(p.byteOrder().Uint32(data))
+		if err := p.checkArrayLength(length); err != nil {
+			return err
+		}
+		if err := p.addReference(nil); err != nil {
+			return err
+		}
+		d.handler.OnArrayStart(name, length)
+		for i := 0; i < length; i++ {
+			elementMarker, err := p.readByte()
+			if err != nil {
+				return err
+			}
+			if err := d.decodeValue(Marker(elementMarker), ""); err != nil {
+				return err
+			}
+		}
+		d.handler.OnArrayEnd()
+	case Reference:
+		index, err := p.readReferenceIndex()
+		if err != nil {
+			return err
+		}
+		d.handler.OnValue(marker, name, uint16(index))
+	default:
+		value := &Value{
+			Marker: marker,
+			Name:   name,
+		}
+		// parseValue counts the depth again
+		p.depth--
+		err := p.parseValue(value)
+		p.depth++
+		if err != nil {
+			return err
+		}
+		d.handler.OnValue(value.Marker, name, value.Value)
+	}
+	return nil
+}
+
+// decodeProperties reads the properties like parseProperties and returns the amount of properties read.
+func (d *Decoder) decodeProperties(limit int) (int, error) {
+	p := d.parser
+	count := 0
+	for {
+		if count == limit {
+			return count, p.readCountedEnd(count)
+		}
+		name, nameLength, err := p.readName()
+		if err != nil {
+			return count, unterminatedError(err)
+		}
+		marker, err := p.readByte()
+		if err != nil {
+			return count, unterminatedError(err)
+		}
+		// Check if 'ObjectEnd'
+		if nameLength == 0 {
+			if marker != ObjectEnd {
+				return count, objectEndError(marker)
+			}
+			return count, nil
+		}
+		if err := d.decodeValue(Marker(marker), name); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
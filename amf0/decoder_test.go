@@ -0,0 +1,99 @@
+package amf0_test
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+// recorder records the calls of a Decoder.
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) OnValue(marker amf0.Marker, name string, value interface{}) {
+	r.events = append(r.events, fmt.Sprintf("value %v %q %v", marker, name, value))
+}
+
+func (r *recorder) OnObjectStart(marker amf0.Marker, name string, className string) {
+	r.events = append(r.events, fmt.Sprintf("start %v %q %q", marker, name, className))
+}
+
+func (r *recorder) OnObjectEnd() {
+	r.events = append(r.events, "end")
+}
+
+func (r *recorder) OnArrayStart(name string, length int) {
+	r.events = append(r.events, fmt.Sprintf("array %q %d", name, length))
+}
+
+func (r *recorder) OnArrayEnd() {
+	r.events = append(r.events, "array end")
+}
+
+func TestDecode(t *testing.T) {
+	data := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("nested").TypedObject("Foo", func(b *amf0test.Builder) {
+			b.Name("a").Number(1)
+		})
+		b.Name("list").StrictArray(2, func(b *amf0test.Builder) {
+			b.String("x").Reference(1)
+		})
+		// The counted ECMAArray is not terminated
+		b.Name("counted").Marker(amf0.ECMAArray).Uint32(1).Name("b").Boolean(true)
+		b.Name("c").Null()
+	}).Bytes()
+	p := amf0.New(bytes.NewReader(data))
+	p.CountedECMAArrays = true
+	r := &recorder{}
+	bytesRead, err := amf0.NewDecoder(p, r).Decode()
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if bytesRead != len(data) {
+		t.Fatalf("read %d bytes, expected %d", bytesRead, len(data))
+	}
+	expected := []string{
+		`start Object "" ""`,
+		`start TypedObject "nested" "Foo"`,
+		`value Number "a" 1`,
+		`end`,
+		`array "list" 2`,
+		`value String "" x`,
+		`value Reference "" 1`,
+		`array end`,
+		`start ECMAArray "counted" ""`,
+		`value Boolean "b" true`,
+		`end`,
+		`value Null "c" <nil>`,
+		`end`,
+	}
+	if !reflect.DeepEqual(r.events, expected) {
+		t.Fatalf("got\n%s\nexpected\n%s", strings.Join(r.events, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestDecodeStrictClassName(t *testing.T) {
+	data := amf0test.New().TypedObject("", func(b *amf0test.Builder) {}).Bytes()
+	p := amf0.New(bytes.NewReader(data))
+	var warnings []string
+	p.OnWarning = func(offset int, warning string) {
+		warnings = append(warnings, warning)
+	}
+	if _, err := amf0.NewDecoder(p, &recorder{}).Decode(); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0] != "typed object has an empty class name" {
+		t.Fatalf("got warnings %q, expected one for the empty class name", warnings)
+	}
+	p = amf0.New(bytes.NewReader(data))
+	p.StrictClassName = true
+	if _, err := amf0.NewDecoder(p, &recorder{}).Decode(); err == nil || !strings.Contains(err.Error(), "empty class name") {
+		t.Fatalf("got error %v, expected one for the empty class name", err)
+	}
+}
@@ -0,0 +1,359 @@
+package amf0
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/balazshorvath/goamf/amf3"
+)
+
+// Encoder writes Value trees in the AMF0 format.
+// Objects, ECMAArrays, TypedObjects and StrictArrays are added to the reference table in the same order,
+// as the parser reads them. When the same *Value is encoded again, a Reference is written instead of it,
+// which also allows encoding trees with cycles. The values read by a Reference are written as a Reference too,
+// while they have the content of the container. Marshal does the same for the pointers to structs and the maps.
+// MapsAsECMAArray makes Marshal encode maps as ECMAArrays instead of Objects.
+// The Marker of a string value decides between String and LongString, a String too long for it is written
+// as a LongString. ForceLongString writes every String value as a LongString, ForceString writes every LongString
+// value as a String, which makes the strings too long for it an error. The property and class names are not affected.
+// AvmPlusObjects are written with the amf3 package, the *amf3.Value after the marker, so the values switch to AMF3
+// where they were read from AMF3.
+// Dates are written from their time.Time with a zero time zone, see NewDate for the precision, parsed Dates
+// are written as they were read, unless the time was changed.
+// Booleans are written as 0x01 and 0x00, PreserveBooleans writes true Booleans with the nonzero byte they were
+// parsed from instead.
+//
+// The encoder doesn't buffer, the values are written to the writer while they are traversed, so only the
+// path to the value being written is kept in memory, besides the reference table. Many small writes are made,
+// the writer should be buffered, like a *bufio.Writer, which has to be flushed with Flush after the last value.
+type Encoder struct {
+	MapsAsECMAArray  bool
+	ForceLongString  bool
+	ForceString      bool
+	PreserveBooleans bool
+
+	writer       io.Writer
+	bytesWritten int
+	references   map[*Value]int
+	// The pointers to structs and the maps written by Marshal
+	objects map[object]int
+	// The amount of values in the reference table, including the ones written by Marshal
+	referenceCount int
+	registry       *ClassRegistry
+	// AMF3 has it's own reference tables, they are kept for every AvmPlusObject written by this encoder
+	amf3 *amf3.Encoder
+	// Set for EncodedSize, the bytes are only counted
+	sizeOnly bool
+	// Used for the fixed size writes to avoid allocations
+	scratch [8]byte
+}
+
+func NewEncoder(writer io.Writer) *Encoder {
+	return &Encoder{
+		writer:     writer,
+		references: make(map[*Value]int),
+		objects:    make(map[object]int),
+		registry:   DefaultClassRegistry,
+	}
+}
+
+// Encode writes the value with it's marker.
+func (e *Encoder) Encode(value *Value) error {
+	if err := e.encodeValue(value); err != nil {
+		return fmt.Errorf("offset %d: %w", e.bytesWritten, err)
+	}
+	return nil
+}
+
+// EncodedSize returns the amount of bytes written by a new Encoder with the default options for the value,
+// or -1 if it can't be encoded.
+func (v *Value) EncodedSize() int {
+	size, err := NewEncoder(nil).EncodedSize(v)
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+// EncodedSize returns the amount of bytes Encode writes for the value with the options of the encoder,
+// or the error it returns, from a new reference table. The bytes are counted without writing them,
+// so the strings are not copied. The encoder is not changed.
+func (e *Encoder) EncodedSize(value *Value) (int, error) {
+	sizer := NewEncoder(nil)
+	sizer.MapsAsECMAArray = e.MapsAsECMAArray
+	sizer.ForceLongString = e.ForceLongString
+	sizer.ForceString = e.ForceString
+	sizer.PreserveBooleans = e.PreserveBooleans
+	sizer.registry = e.registry
+	sizer.sizeOnly = true
+	if err := sizer.Encode(value); err != nil {
+		return 0, err
+	}
+	return sizer.bytesWritten, nil
+}
+
+// Flush flushes the writer, if it has a Flush method, like a *bufio.Writer.
+func (e *Encoder) Flush() error {
+	if flusher, ok := e.writer.(interface{ Flush() error }); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+func (e *Encoder) encodeValue(value *Value) error {
+	if value == nil {
+		return e.writeByte(Null)
+	}
+	if raw, ok := value.Value.(RawValue); ok {
+		return e.encodeRaw(raw)
+	}
+	switch value.Marker {
+	case Object, ECMAArray, TypedObject, StrictArray:
+		key := value
+		if value.ref != nil && sameContent(value, value.ref) {
+			key = value.ref
+		}
+		if index, ok := e.references[key]; ok {
+			return e.writeReference(index)
+		}
+		e.references[key] = e.referenceCount
+		e.referenceCount++
+	}
+	marker := value.Marker
+	if str, ok := value.Value.(string); ok && (marker == String || marker == LongString) {
+		var err error
+		if marker, err = e.stringMarker(marker, str); err != nil {
+			return err
+		}
+	}
+	if err := e.writeByte(byte(marker)); err != nil {
+		return err
+	}
+	switch value.Marker {
+	case Number:
+		number, ok := value.Value.(float64)
+		if !ok {
+			return invalidValue(value)
+		}
+		return e.writeDouble(number)
+	case Boolean:
+		b, ok := value.Value.(bool)
+		if !ok {
+			return invalidValue(value)
+		}
+		if b {
+			if e.PreserveBooleans && value.boolean > 1 {
+				return e.writeByte(value.boolean)
+			}
+			return e.writeByte(1)
+		}
+		return e.writeByte(0)
+	case LongString, XmlDocument, String:
+		// LongString and XmlDocument have 4 bytes for the length
+		str, ok := value.Value.(string)
+		if !ok {
+			return invalidValue(value)
+		}
+		return e.writeString(marker, str)
+	case Object:
+		properties, ok := value.Value.([]*Value)
+		if !ok && value.Value != nil {
+			return invalidValue(value)
+		}
+		return e.encodeProperties(properties)
+	case Null, Undefined, Unsupported:
+		return nil
+	case ECMAArray:
+		properties, ok := value.Value.([]*Value)
+		if !ok && value.Value != nil {
+			return invalidValue(value)
+		}
+		if err := e.writeUint32(uint32(len(properties))); err != nil {
+			return err
+		}
+		return e.encodeProperties(properties)
+	case StrictArray:
+		values, ok := value.Value.([]*Value)
+		if !ok && value.Value != nil {
+			return invalidValue(value)
+		}
+		if err := e.writeUint32(uint32(len(values))); err != nil {
+			return err
+		}
+		for _, arrayValue := range values {
+			if err := e.encodeValue(arrayValue); err != nil {
+				return err
+			}
+		}
+		return nil
+	case Date:
+		t, ok := value.Value.(time.Time)
+		if !ok {
+			return invalidValue(value)
+		}
+		// Parsed Dates are written as they were read, unless the time was changed
+		if date := value.date; date != nil && (math.IsNaN(date.millis) || millisToTime(date.millis).Equal(t)) {
+			if err := e.writeUint16(uint16(date.timezone)); err != nil {
+				return err
+			}
+			return e.writeDouble(date.millis)
+		}
+		// Time zone is not supported, should be 0
+		if err := e.writeUint16(0); err != nil {
+			return err
+		}
+		return e.writeDouble(timeToMillis(t))
+	case TypedObject:
+		properties, ok := value.Value.([]*Value)
+		if !ok && value.Value != nil {
+			return invalidValue(value)
+		}
+		if err := e.writeString(String, value.ClassName); err != nil {
+			return err
+		}
+		return e.encodeProperties(properties)
+	case AvmPlusObject:
+		amf3Value, ok := value.Value.(*amf3.Value)
+		if !ok {
+			return invalidValue(value)
+		}
+		if e.amf3 == nil {
+			e.amf3 = amf3.NewEncoder(amf3Writer{e})
+		}
+		return e.amf3.Encode(amf3Value)
+	default:
+		return &UnsupportedMarkerError{Marker: value.Marker}
+	}
+}
+
+// stringMarker returns the marker the String or LongString value is written with.
+func (e *Encoder) stringMarker(marker Marker, str string) (Marker, error) {
+	switch {
+	case e.ForceString && e.ForceLongString:
+		return marker, errors.New("ForceString and ForceLongString can not be set both")
+	case e.ForceString:
+		if len(str) > math.MaxUint16 {
+			return marker, fmt.Errorf("string of length %d does not fit into a String, ForceString is set", len(str))
+		}
+		return String, nil
+	case e.ForceLongString, len(str) > math.MaxUint16:
+		return LongString, nil
+	}
+	return marker, nil
+}
+
+// sameContent reports whether the value read by a Reference still has the content of the container.
+func sameContent(value *Value, container *Value) bool {
+	if value.Marker != container.Marker || value.ClassName != container.ClassName {
+		return false
+	}
+	values, ok := value.Value.([]*Value)
+	containerValues, containerOK := container.Value.([]*Value)
+	if !ok || !containerOK {
+		return value.Value == nil && container.Value == nil
+	}
+	return len(values) == len(containerValues) && (len(values) == 0 || &values[0] == &containerValues[0])
+}
+
+func (e *Encoder) writeReference(index int) error {
+	if index > math.MaxUint16 {
+		return fmt.Errorf("reference index %d does not fit into a Reference", index)
+	}
+	if err := e.writeByte(Reference); err != nil {
+		return err
+	}
+	return e.writeUint16(uint16(index))
+}
+
+func (e *Encoder) encodeProperties(properties []*Value) error {
+	for _, property := range properties {
+		if property.Name == "" {
+			return errors.New("property name can not be empty, it would be read as 'ObjectEnd'")
+		}
+		if err := e.writeString(String, property.Name); err != nil {
+			return err
+		}
+		if err := e.encodeValue(property); err != nil {
+			return err
+		}
+	}
+	return e.writeBytes(objectEnd)
+}
+
+func (e *Encoder) writeDouble(value float64) error {
+	data := e.scratch[:8]
+	binary.BigEndian.PutUint64(data, math.Float64bits(value))
+	return e.writeBytes(data)
+}
+
+func (e *Encoder) writeUint16(value uint16) error {
+	data := e.scratch[:2]
+	binary.BigEndian.PutUint16(data, value)
+	return e.writeBytes(data)
+}
+
+func (e *Encoder) writeUint32(value uint32) error {
+	data := e.scratch[:4]
+	binary.BigEndian.PutUint32(data, value)
+	return e.writeBytes(data)
+}
+
+func (e *Encoder) writeString(marker Marker, str string) error {
+	if marker == String {
+		if len(str) > math.MaxUint16 {
+			return fmt.Errorf("string of length %d does not fit into a String", len(str))
+		}
+		if err := e.writeUint16(uint16(len(str))); err != nil {
+			return err
+		}
+	} else if err := e.writeUint32(uint32(len(str))); err != nil {
+		return err
+	}
+	if e.sizeOnly {
+		e.bytesWritten += len(str)
+		return nil
+	}
+	return e.writeBytes([]byte(str))
+}
+
+// objectEnd is the empty name and the 'ObjectEnd' marker terminating the properties.
+var objectEnd = []byte{0, 0, ObjectEnd}
+
+func (e *Encoder) writeByte(b byte) error {
+	e.scratch[0] = b
+	return e.writeBytes(e.scratch[:1])
+}
+
+func (e *Encoder) writeBytes(data []byte) error {
+	if e.sizeOnly {
+		e.bytesWritten += len(data)
+		return nil
+	}
+	n, err := e.writer.Write(data)
+	e.bytesWritten += n
+	return err
+}
+
+// amf3Writer writes the AMF3 values through the encoder, so the bytes are counted.
+type amf3Writer struct {
+	e *Encoder
+}
+
+func (w amf3Writer) Write(data []byte) (int, error) {
+	bytesWritten := w.e.bytesWritten
+	err := w.e.writeBytes(data)
+	return w.e.bytesWritten - bytesWritten, err
+}
+
+func invalidValue(value *Value) error {
+	return fmt.Errorf("invalid value of type %T for marker %v", value.Value, value.Marker)
+}
+
+// timeToMillis converts the time to milliseconds since epoch, precision below milliseconds is kept as fraction.
+func timeToMillis(t time.Time) float64 {
+	return float64(t.Unix())*1000 + float64(t.Nanosecond())/float64(time.Millisecond)
+}
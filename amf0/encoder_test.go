@@ -0,0 +1,231 @@
+package amf0_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+// roundTrip parses the data and encodes the value again.
+func roundTrip(t *testing.T, data []byte) (*amf0.Value, []byte) {
+	t.Helper()
+	value, _, err := amf0.Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var buffer bytes.Buffer
+	if err := amf0.NewEncoder(&buffer).Encode(value); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	return value, buffer.Bytes()
+}
+
+func TestRoundTripReferences(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"repeated array", amf0test.New().StrictArray(2, func(b *amf0test.Builder) {
+			b.StrictArray(2, func(b *amf0test.Builder) {
+				b.Number(1).String("foo")
+			}).Reference(1)
+		}).Bytes()},
+		{"repeated empty object", amf0test.New().StrictArray(2, func(b *amf0test.Builder) {
+			b.Object(func(b *amf0test.Builder) {}).Reference(1)
+		}).Bytes()},
+		{"self reference", amf0test.New().Object(func(b *amf0test.Builder) {
+			b.Name("self").Reference(0)
+		}).Bytes()},
+		{"reference to the parent", amf0test.New().Object(func(b *amf0test.Builder) {
+			b.Name("child").Object(func(b *amf0test.Builder) {
+				b.Name("parent").Reference(0)
+			})
+		}).Bytes()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, encoded := roundTrip(t, test.data)
+			if !bytes.Equal(encoded, test.data) {
+				t.Fatalf("encoded % x, expected % x", encoded, test.data)
+			}
+		})
+	}
+}
+
+func TestParseSelfReference(t *testing.T) {
+	data := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("id").Number(1).Name("self").Reference(0)
+	}).Bytes()
+	value, _, err := amf0.Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	self, _ := value.Property("self")
+	if id, ok := self.Property("id"); !ok || id.Value != 1.0 {
+		t.Fatalf("the Reference to the object being parsed has no content: %v", self)
+	}
+}
+
+func TestEncodeStringMarker(t *testing.T) {
+	long := strings.Repeat("x", 70000)
+	tests := []struct {
+		name   string
+		value  *amf0.Value
+		setup  func(e *amf0.Encoder)
+		marker amf0.Marker
+		fails  bool
+	}{
+		{"short String", &amf0.Value{Marker: amf0.String, Value: "foo"}, nil, amf0.String, false},
+		{"long String", &amf0.Value{Marker: amf0.String, Value: long}, nil, amf0.LongString, false},
+		{"short LongString", &amf0.Value{Marker: amf0.LongString, Value: "foo"}, nil, amf0.LongString, false},
+		{"ForceLongString", &amf0.Value{Marker: amf0.String, Value: "foo"},
+			func(e *amf0.Encoder) { e.ForceLongString = true }, amf0.LongString, false},
+		{"ForceString", &amf0.Value{Marker: amf0.LongString, Value: "foo"},
+			func(e *amf0.Encoder) { e.ForceString = true }, amf0.String, false},
+		{"ForceString long", &amf0.Value{Marker: amf0.String, Value: long},
+			func(e *amf0.Encoder) { e.ForceString = true }, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			e := amf0.NewEncoder(&buffer)
+			if test.setup != nil {
+				test.setup(e)
+			}
+			err := e.Encode(test.value)
+			if test.fails {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			value, _, err := amf0.Parse(buffer.Bytes())
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if value.Marker != test.marker || value.Value != test.value.Value {
+				t.Fatalf("got %v of length %d, expected %v", value.Marker, len(value.Value.(string)), test.marker)
+			}
+		})
+	}
+}
+
+func TestEncodeBoolean(t *testing.T) {
+	parsed, _, err := amf0.Parse(amf0test.New().Marker(amf0.Boolean).Raw(0x7F).Bytes())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	tests := []struct {
+		name     string
+		value    *amf0.Value
+		preserve bool
+		expected []byte
+	}{
+		{"true", amf0.NewBool(true), false, []byte{amf0.Boolean, 0x01}},
+		{"false", amf0.NewBool(false), false, []byte{amf0.Boolean, 0x00}},
+		{"parsed true", parsed, false, []byte{amf0.Boolean, 0x01}},
+		{"PreserveBooleans", parsed, true, []byte{amf0.Boolean, 0x7F}},
+		{"PreserveBooleans new value", amf0.NewBool(true), true, []byte{amf0.Boolean, 0x01}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			e := amf0.NewEncoder(&buffer)
+			e.PreserveBooleans = test.preserve
+			if err := e.Encode(test.value); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if !bytes.Equal(buffer.Bytes(), test.expected) {
+				t.Fatalf("encoded % x, expected % x", buffer.Bytes(), test.expected)
+			}
+		})
+	}
+}
+
+func TestRoundTripDuplicateProperties(t *testing.T) {
+	data := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("a").Number(1).Name("b").Null().Name("a").Number(2)
+	}).Bytes()
+	value, encoded := roundTrip(t, data)
+	if !bytes.Equal(encoded, data) {
+		t.Fatalf("encoded % x, expected % x", encoded, data)
+	}
+	if property, _ := value.Property("a"); property.Value != 1.0 {
+		t.Errorf("Property returned %v, expected the first one", property)
+	}
+	if _, err := value.AsMap(); err == nil {
+		t.Error("AsMap of duplicate properties returned no error")
+	}
+	native, err := value.ToNative()
+	if err != nil {
+		t.Fatalf("ToNative failed: %v", err)
+	}
+	if a := native.(map[string]interface{})["a"]; a != 2.0 {
+		t.Errorf("ToNative kept %v, expected the last one", a)
+	}
+}
+
+func TestEncodedSize(t *testing.T) {
+	value, _, err := amf0.Parse(amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("name").String("foo").Name("flag").Marker(amf0.Boolean).Raw(0x7F).
+			Name("tags").StrictArray(2, func(b *amf0test.Builder) {
+			b.Number(3).Date(0, 60)
+		}).Name("again").Reference(1).Name("long").LongString("bar")
+	}).Bytes())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	long := amf0.NewObject(&amf0.Value{Name: "s", Marker: amf0.String, Value: strings.Repeat("x", 70000)})
+	tests := []struct {
+		name  string
+		value *amf0.Value
+		setup func(e *amf0.Encoder)
+	}{
+		{"parsed", value, nil},
+		{"ForceLongString", value, func(e *amf0.Encoder) { e.ForceLongString = true }},
+		{"ForceString", value, func(e *amf0.Encoder) { e.ForceString = true }},
+		{"PreserveBooleans", value, func(e *amf0.Encoder) { e.PreserveBooleans = true }},
+		{"long String", long, nil},
+		{"ForceString long", long, func(e *amf0.Encoder) { e.ForceString = true }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			e := amf0.NewEncoder(&buffer)
+			if test.setup != nil {
+				test.setup(e)
+			}
+			size, sizeErr := e.EncodedSize(test.value)
+			if err := e.Encode(test.value); err != nil {
+				if sizeErr == nil {
+					t.Fatalf("EncodedSize returned %d, Encode failed: %v", size, err)
+				}
+				return
+			}
+			if sizeErr != nil || size != buffer.Len() {
+				t.Fatalf("EncodedSize returned %d, %v, Encode wrote %d bytes", size, sizeErr, buffer.Len())
+			}
+			if test.setup == nil && test.value.EncodedSize() != size {
+				t.Fatalf("Value.EncodedSize returned %d, expected %d", test.value.EncodedSize(), size)
+			}
+		})
+	}
+}
+
+func TestEncodedSizeAllocations(t *testing.T) {
+	strs := make([]*amf0.Value, 100)
+	for i := range strs {
+		strs[i] = amf0.NewString(strings.Repeat("x", 1000))
+	}
+	value := amf0.NewStrictArray(strs...)
+	// The reference table and the encoder are allocated, the strings are not copied
+	if allocs := testing.AllocsPerRun(10, func() { value.EncodedSize() }); allocs > 10 {
+		t.Fatalf("EncodedSize made %v allocations", allocs)
+	}
+}
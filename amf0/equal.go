@@ -0,0 +1,104 @@
+package amf0
+
+import (
+	"math"
+	"reflect"
+)
+
+// Comparer compares value trees, the zero value compares numbers exactly and NaN is not equal to anything.
+// Epsilon is the largest difference of numbers and Date milliseconds still equal.
+// NaNEqual makes NaN equal to NaN.
+type Comparer struct {
+	Epsilon  float64
+	NaNEqual bool
+}
+
+// Equal reports whether the value trees are equal, like a Comparer with NaNEqual set.
+func (v *Value) Equal(other *Value) bool {
+	c := Comparer{NaNEqual: true}
+	return c.Equal(v, other)
+}
+
+// Equal reports whether the value trees are equal. The markers, the names and the class names have to match.
+// Properties are compared by name regardless of their order, properties with the same name in order.
+// Array elements are compared in order. The declared Count and the ByteLength are not compared,
+// a nil value equals a Null. Containers appearing more than once, like the ones read by References,
+// are compared once.
+func (c *Comparer) Equal(a *Value, b *Value) bool {
+	return c.equal(a, b, make(map[[2]interface{}]bool))
+}
+
+// equal compares the values, compared has the pairs of identities being compared or found equal,
+// they are equal when they appear again. A difference ends the comparison, so no pair is found different.
+func (c *Comparer) equal(a *Value, b *Value, compared map[[2]interface{}]bool) bool {
+	// Encoded as Null
+	if a == nil {
+		a = NewNull()
+	}
+	if b == nil {
+		b = NewNull()
+	}
+	if a.Marker != b.Marker || a.Name != b.Name || a.ClassName != b.ClassName {
+		return false
+	}
+	pair := [2]interface{}{identity(a), identity(b)}
+	if compared[pair] {
+		return true
+	}
+	compared[pair] = true
+	switch a.Marker {
+	case Number:
+		x, ok := a.Float64()
+		y, ok2 := b.Float64()
+		return ok && ok2 && c.equalNumbers(x, y)
+	case Date:
+		x, ok := a.DateMillis()
+		y, ok2 := b.DateMillis()
+		return ok && ok2 && c.equalNumbers(x, y)
+	case Object, ECMAArray, TypedObject:
+		x, ok := a.properties()
+		y, ok2 := b.properties()
+		if !ok || !ok2 || len(x) != len(y) {
+			return false
+		}
+		byName := make(map[string][]*Value, len(y))
+		for _, property := range y {
+			if property == nil {
+				return false
+			}
+			byName[property.Name] = append(byName[property.Name], property)
+		}
+		for _, property := range x {
+			if property == nil {
+				return false
+			}
+			candidates := byName[property.Name]
+			if len(candidates) == 0 || !c.equal(property, candidates[0], compared) {
+				return false
+			}
+			byName[property.Name] = candidates[1:]
+		}
+		return true
+	case StrictArray:
+		x, ok := a.Value.([]*Value)
+		y, ok2 := b.Value.([]*Value)
+		if (!ok && a.Value != nil) || (!ok2 && b.Value != nil) || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !c.equal(x[i], y[i], compared) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a.Value, b.Value)
+	}
+}
+
+func (c *Comparer) equalNumbers(x float64, y float64) bool {
+	if math.IsNaN(x) || math.IsNaN(y) {
+		return c.NaNEqual && math.IsNaN(x) && math.IsNaN(y)
+	}
+	return x == y || math.Abs(x-y) <= c.Epsilon
+}
@@ -0,0 +1,65 @@
+package amf0_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf0"
+)
+
+func TestEqual(t *testing.T) {
+	named := func(name string, v *amf0.Value) *amf0.Value {
+		v.Name = name
+		return v
+	}
+	tests := []struct {
+		name     string
+		comparer amf0.Comparer
+		a, b     *amf0.Value
+		equal    bool
+	}{
+		{"properties in any order", amf0.Comparer{},
+			amf0.NewObject(named("a", amf0.NewNumber(1)), named("b", amf0.NewString("x"))),
+			amf0.NewObject(named("b", amf0.NewString("x")), named("a", amf0.NewNumber(1))), true},
+		{"duplicate properties in order", amf0.Comparer{},
+			amf0.NewObject(named("a", amf0.NewNumber(1)), named("a", amf0.NewNumber(2))),
+			amf0.NewObject(named("a", amf0.NewNumber(2)), named("a", amf0.NewNumber(1))), false},
+		{"elements in order", amf0.Comparer{},
+			amf0.NewStrictArray(amf0.NewNumber(1), amf0.NewNumber(2)),
+			amf0.NewStrictArray(amf0.NewNumber(2), amf0.NewNumber(1)), false},
+		{"different names", amf0.Comparer{}, named("a", amf0.NewNull()), named("b", amf0.NewNull()), false},
+		{"nil and Null", amf0.Comparer{}, nil, amf0.NewNull(), true},
+		{"NaN", amf0.Comparer{}, amf0.NewNumber(math.NaN()), amf0.NewNumber(math.NaN()), false},
+		{"NaNEqual", amf0.Comparer{NaNEqual: true}, amf0.NewNumber(math.NaN()), amf0.NewNumber(math.NaN()), true},
+		{"Epsilon", amf0.Comparer{Epsilon: 0.01}, amf0.NewNumber(1), amf0.NewNumber(1.005), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.comparer.Equal(test.a, test.b); got != test.equal {
+				t.Fatalf("Equal returned %v, expected %v", got, test.equal)
+			}
+		})
+	}
+}
+
+func TestEqualSharedReferences(t *testing.T) {
+	value, _, err := amf0.Parse(sharedReferences(30))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	other, _, err := amf0.Parse(sharedReferences(30))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !value.Equal(other) {
+		t.Fatal("the trees are not equal")
+	}
+	// The innermost array is shared by every appearance
+	if err := other.SetPath(strings.Repeat("[0]", 30)+"[1]", amf0.NewNumber(3), false); err != nil {
+		t.Fatalf("SetPath failed: %v", err)
+	}
+	if value.Equal(other) {
+		t.Fatal("the trees are equal after changing the innermost array")
+	}
+}
@@ -0,0 +1,34 @@
+package amf0
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ParseFLVMetadata parses the data of an FLV script tag, like onMetaData.
+// It's a String with the name and an ECMAArray with the properties.
+// Some encoders write an Object instead of the ECMAArray or an invalid associative count, these are accepted.
+func ParseFLVMetadata(data []byte) (string, map[string]*Value, error) {
+	p := New(bytes.NewReader(data))
+	p.Lenient = true
+	nameValue, _, err := p.Parse()
+	if err != nil {
+		return "", nil, err
+	}
+	name, ok := nameValue.StringValue()
+	if !ok {
+		return "", nil, fmt.Errorf("expected a String as the script tag name, got marker %v", nameValue.Marker)
+	}
+	metaValue, _, err := p.Parse()
+	if err != nil {
+		return "", nil, err
+	}
+	if metaValue.Marker != ECMAArray && metaValue.Marker != Object {
+		return "", nil, fmt.Errorf("expected an ECMAArray as the script tag data, got marker %v", metaValue.Marker)
+	}
+	meta, err := metaValue.AsMap()
+	if err != nil {
+		return "", nil, err
+	}
+	return name, meta, nil
+}
@@ -0,0 +1,49 @@
+package amf0
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ParseFrame reads a 4 bytes big-endian length and a value of exactly that many bytes with a new parser.
+// The rest of the frame is read even if the value is shorter, so the reader stays at the next frame.
+// If the reader had no more frames, the error is io.EOF, a frame ending early results in io.ErrUnexpectedEOF.
+func ParseFrame(r io.Reader) (*Value, error) {
+	var data [4]byte
+	if _, err := io.ReadFull(r, data[:]); err != nil {
+		return nil, fmt.Errorf("reading frame length: %w", err)
+	}
+	length := int64(binary.BigEndian.Uint32(data[:]))
+	frame := io.LimitReader(r, length)
+	value, bytesRead, err := New(frame).Parse()
+	if err != nil {
+		if err2 := discardFrame(frame); err2 != nil {
+			return nil, fmt.Errorf("frame of %d bytes: %w", length, err2)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("frame of %d bytes: %w", length, io.ErrUnexpectedEOF)
+		}
+		return nil, fmt.Errorf("frame of %d bytes: %w", length, err)
+	}
+	if int64(bytesRead) != length {
+		if err := discardFrame(frame); err != nil {
+			return nil, fmt.Errorf("frame of %d bytes: %w", length, err)
+		}
+		return nil, fmt.Errorf("frame of %d bytes has a value of %d bytes", length, bytesRead)
+	}
+	return value, nil
+}
+
+// discardFrame reads the rest of the frame, which ending early is an io.ErrUnexpectedEOF.
+func discardFrame(frame io.Reader) error {
+	if _, err := io.Copy(ioutil.Discard, frame); err != nil {
+		return err
+	}
+	if frame.(*io.LimitedReader).N > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package amf0_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+// fuzzSeeds are valid values of every kind, with references, for the fuzz targets to start from.
+func fuzzSeeds() [][]byte {
+	return [][]byte{
+		amf0test.New().Number(3.14).Bytes(),
+		amf0test.New().Boolean(true).Bytes(),
+		amf0test.New().String("foo").Bytes(),
+		amf0test.New().LongString("foo").Bytes(),
+		amf0test.New().XmlDocument("<a/>").Bytes(),
+		amf0test.New().Null().Undefined().Unsupported().Bytes(),
+		amf0test.New().Date(1577836800000, 60).Bytes(),
+		amf0test.New().Object(func(b *amf0test.Builder) {
+			b.Name("a").Number(1).Name("self").Reference(0)
+		}).Bytes(),
+		amf0test.New().ECMAArray(2, func(b *amf0test.Builder) {
+			b.Name("x").String("y").Name("z").Null()
+		}).Bytes(),
+		amf0test.New().TypedObject("Point", func(b *amf0test.Builder) {
+			b.Name("x").Number(1).Name("y").Number(2)
+		}).Bytes(),
+		amf0test.New().StrictArray(3, func(b *amf0test.Builder) {
+			b.Object(func(b *amf0test.Builder) {}).Reference(1).StrictArray(0, func(b *amf0test.Builder) {})
+		}).Bytes(),
+		// An AMF3 String
+		amf0test.New().Marker(amf0.AvmPlusObject).Raw(0x06, 0x07, 'f', 'o', 'o').Bytes(),
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		value, bytesRead, err := amf0.Parse(data)
+		if err != nil {
+			return
+		}
+		var buffer bytes.Buffer
+		if err := amf0.NewEncoder(&buffer).Encode(value); err != nil {
+			return
+		}
+		// The encoder normalizes the values, like the Booleans, but it has to write data the parser reads back
+		if _, n, err := amf0.Parse(buffer.Bytes()); err != nil || n != buffer.Len() {
+			t.Fatalf("parsing the encoded % x of % x read %d bytes: %v", buffer.Bytes(), data[:bytesRead], n, err)
+		}
+	})
+}
+
+func FuzzSkip(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		n, err := amf0.New(bytes.NewReader(data)).Skip()
+		if err != nil {
+			return
+		}
+		// Skip checks less than Parse, but a value read has to be skipped the same way
+		if _, bytesRead, err := amf0.Parse(data); err == nil && bytesRead != n {
+			t.Fatalf("skipped %d bytes, the value has %d", n, bytesRead)
+		}
+	})
+}
+
+func FuzzParseNetConnectionPacket(f *testing.F) {
+	for _, seed := range fuzzSeeds() {
+		// Version 0, no headers, a message with the value as the body
+		// The target and response uris are strings without markers, like the names
+		f.Add(amf0test.New().Uint16(0).Uint16(0).Uint16(1).
+			Name("target").Name("/1").Uint32(uint32(len(seed))).Raw(seed...).Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		packet, err := amf0.ParseNetConnectionPacket(data)
+		if err != nil {
+			return
+		}
+		encoded, err := packet.Encode()
+		if err != nil {
+			return
+		}
+		if _, err := amf0.ParseNetConnectionPacket(encoded); err != nil {
+			t.Fatalf("parsing the encoded % x failed: %v", encoded, err)
+		}
+	})
+}
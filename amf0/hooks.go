@@ -0,0 +1,45 @@
+package amf0
+
+// OnTypedObject sets fn to be called with every TypedObject of the class, as soon as it's read.
+// The hooks are called after the properties are read, so the hooks of the nested values are called first.
+// The values read again by a Reference and the values skipped by ParseFiltered are not passed to the hooks.
+// The hook can change the value, the tree returned by Parse has the changed value. A nil fn removes the hook.
+// The IncrementalParser calls the hooks again for the values of an incomplete value, when it's parsed again.
+func (p *Parser) OnTypedObject(className string, fn func(v *Value)) {
+	if fn == nil {
+		delete(p.typedObjectHooks, className)
+		return
+	}
+	if p.typedObjectHooks == nil {
+		p.typedObjectHooks = make(map[string]func(v *Value))
+	}
+	p.typedObjectHooks[className] = fn
+}
+
+// OnDate sets fn to be called with every Date, as soon as it's read, like OnTypedObject.
+func (p *Parser) OnDate(fn func(v *Value)) {
+	p.dateHook = fn
+}
+
+// OnECMAArray sets fn to be called with every ECMAArray, as soon as it's read, like OnTypedObject.
+func (p *Parser) OnECMAArray(fn func(v *Value)) {
+	p.ecmaArrayHook = fn
+}
+
+// decoded calls the hook of the value read from start.
+func (p *Parser) decoded(value *Value, start int) {
+	var fn func(v *Value)
+	switch value.Marker {
+	case TypedObject:
+		fn = p.typedObjectHooks[value.ClassName]
+	case Date:
+		fn = p.dateHook
+	case ECMAArray:
+		fn = p.ecmaArrayHook
+	}
+	if fn != nil {
+		// The hook sees the value complete
+		value.ByteLength = p.reader.count - start
+		fn(value)
+	}
+}
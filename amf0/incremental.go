@@ -0,0 +1,120 @@
+package amf0
+
+import (
+	"errors"
+	"io"
+
+	"github.com/balazshorvath/goamf/amf3"
+)
+
+// IncrementalParser parses the values from chunks of data, as they arrive.
+// The options can be set on Parser, which should not be used for parsing directly.
+// The limits apply to each value, like for a single Parser. The reference tables are kept for the whole stream,
+// so a Reference can point to a value fed before, ResetReferences clears them before every value instead,
+// for the streams of independent messages.
+type IncrementalParser struct {
+	Parser          *Parser
+	ResetReferences bool
+
+	reader *feedReader
+	// The bytes the value in the buffer needs at least, it's parsed again when they are buffered
+	needed int
+}
+
+func NewIncrementalParser() *IncrementalParser {
+	reader := &feedReader{}
+	return &IncrementalParser{
+		Parser: New(reader),
+		reader: reader,
+	}
+}
+
+// Feed buffers the data and parses the values completed by it. The returned consumed is the amount of bytes
+// of the returned values, which may include bytes of earlier calls. A value not completed yet is kept
+// in the buffer and parsed again from it's start, once the bytes the failed read needed are buffered,
+// like the whole length of a string. It's not a resumable parser, so a value of many small parts arriving
+// in small chunks is parsed many times, and the hooks and OnWarning are called again for it. Errors other than
+// the data ending early are returned with the values parsed before, the parser should not be used after that,
+// until Reset.
+func (ip *IncrementalParser) Feed(data []byte) ([]*Value, int, error) {
+	ip.reader.feed(data)
+	if len(ip.reader.data) < ip.needed {
+		return nil, 0, nil
+	}
+	ip.needed = 0
+	var values []*Value
+	consumed := 0
+	p := ip.Parser
+	for ip.reader.offset < len(ip.reader.data) {
+		start := ip.reader.offset
+		ip.reader.wanted = 0
+		if ip.ResetReferences {
+			p.clearReferences()
+		}
+		// The state is restored, if the value is not complete yet
+		saved := *p
+		var savedAMF3 amf3.Parser
+		if p.amf3 != nil {
+			savedAMF3 = *p.amf3
+		}
+		p.readEnd = 0
+		value, _, err := p.Parse()
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			ip.needed = maxInt(ip.reader.wanted-start, p.readEnd-p.valueStart)
+			*p = saved
+			if p.amf3 != nil {
+				*p.amf3 = savedAMF3
+			}
+			ip.reader.offset = start
+			break
+		} else if err != nil {
+			return values, consumed, err
+		}
+		values = append(values, value)
+		consumed += ip.reader.offset - start
+	}
+	return values, consumed, nil
+}
+
+// Reset drops the buffered data and clears the state of the parser, the options are kept.
+func (ip *IncrementalParser) Reset() {
+	ip.reader.data = ip.reader.data[:0]
+	ip.reader.offset = 0
+	ip.needed = 0
+	ip.Parser.Reset(ip.reader)
+}
+
+// feedReader reads the buffered data, the offset can be moved back to read it again.
+// Wanted is the end of the last read, that ran out of the data.
+type feedReader struct {
+	data   []byte
+	offset int
+	wanted int
+}
+
+// feed drops the data already read and appends the new data.
+func (r *feedReader) feed(data []byte) {
+	n := copy(r.data, r.data[r.offset:])
+	r.data = append(r.data[:n], data...)
+	r.offset = 0
+}
+
+func (r *feedReader) Read(buffer []byte) (int, error) {
+	if r.offset >= len(r.data) {
+		r.wanted = r.offset + len(buffer)
+		return 0, io.EOF
+	}
+	n := copy(buffer, r.data[r.offset:])
+	r.offset += n
+	return n, nil
+}
+
+func (r *feedReader) ReadByte() (byte, error) {
+	if r.offset >= len(r.data) {
+		r.wanted = r.offset + 1
+		return 0, io.EOF
+	}
+	b := r.data[r.offset]
+	r.offset++
+	return b, nil
+}
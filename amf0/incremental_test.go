@@ -0,0 +1,89 @@
+package amf0_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+func TestIncrementalParserChunks(t *testing.T) {
+	message := amf0test.New().StrictArray(2, func(b *amf0test.Builder) {
+		b.Object(func(b *amf0test.Builder) {
+			b.Name("a").Number(1)
+		}).Reference(1)
+	}).Bytes()
+	data := append(append([]byte{}, message...), message...)
+	ip := amf0.NewIncrementalParser()
+	ip.ResetReferences = true
+	var values []*amf0.Value
+	for i := range data {
+		fed, _, err := ip.Feed(data[i : i+1])
+		if err != nil {
+			t.Fatalf("Feed failed at byte %d: %v", i, err)
+		}
+		values = append(values, fed...)
+	}
+	if len(values) != 2 {
+		t.Fatalf("got %d values, expected 2", len(values))
+	}
+	for _, value := range values {
+		elements := value.Value.([]*amf0.Value)
+		if property, ok := elements[1].Property("a"); !ok || property.Value != 1.0 {
+			t.Errorf("the reference was not resolved: %v", value)
+		}
+	}
+}
+
+func TestIncrementalParserReset(t *testing.T) {
+	ip := amf0.NewIncrementalParser()
+	ip.ResetReferences = true
+	data := amf0test.New().Object(func(b *amf0test.Builder) {}).Reference(0).Bytes()
+	values, _, err := ip.Feed(data)
+	if !errors.Is(err, amf0.ErrInvalidReference) {
+		t.Fatalf("got error %v, expected a Reference to the previous value to fail", err)
+	}
+	if len(values) != 1 {
+		t.Fatalf("got %d values, expected the Object", len(values))
+	}
+	ip.Reset()
+	values, consumed, err := ip.Feed(amf0test.New().Number(1).Bytes())
+	if err != nil || len(values) != 1 || consumed != 9 {
+		t.Fatalf("Feed after Reset got %d values and %d bytes, error %v", len(values), consumed, err)
+	}
+}
+
+func TestIncrementalParserWaitsForTheRead(t *testing.T) {
+	data := amf0test.New().StrictArray(2, func(b *amf0test.Builder) {
+		b.Date(0, 0).LongString(strings.Repeat("x", 100000))
+	}).Bytes()
+	ip := amf0.NewIncrementalParser()
+	parses := 0
+	ip.Parser.OnDate(func(v *amf0.Value) {
+		parses++
+	})
+	var values []*amf0.Value
+	for i := 0; i < len(data); i += 1000 {
+		fed, _, err := ip.Feed(data[i:minInt(i+1000, len(data))])
+		if err != nil {
+			t.Fatalf("Feed failed at byte %d: %v", i, err)
+		}
+		values = append(values, fed...)
+	}
+	if len(values) != 1 {
+		t.Fatalf("got %d values, expected 1", len(values))
+	}
+	// Parsed once up to the length of the string, then once it's buffered
+	if parses != 2 {
+		t.Fatalf("the value was parsed %d times, expected 2", parses)
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,162 @@
+package amf0
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// MarshalJSON renders the value for debugging purposes.
+// Objects, ECMAArrays and TypedObjects become JSON objects with the properties in order,
+// StrictArrays become JSON arrays, Dates RFC3339 strings, Null and Undefined null.
+// Containers appearing again in the tree, like the ones read by References, are rendered once,
+// then as {"$ref":"path"} with the path of their first appearance in the format of Walk.
+// NaN and infinite Numbers and Dates are an error, JSON can't represent them, so are the values containing themselves.
+func (v *Value) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := v.writeJSON(&buffer, "", newRendering()); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// rendering holds the containers of a rendered tree by identity, the ones being rendered
+// and the paths of the ones already rendered.
+type rendering struct {
+	ancestors map[interface{}]bool
+	paths     map[interface{}]string
+}
+
+func newRendering() *rendering {
+	return &rendering{
+		ancestors: make(map[interface{}]bool),
+		paths:     make(map[interface{}]string),
+	}
+}
+
+// enter starts rendering the container, returning the path of it's first appearance if it was rendered already.
+// Rendering it is finished by calling leave.
+func (r *rendering) enter(v *Value, path string) (string, bool) {
+	key := identity(v)
+	if first, ok := r.paths[key]; ok {
+		return first, true
+	}
+	r.ancestors[key] = true
+	r.paths[key] = path
+	return "", false
+}
+
+func (r *rendering) leave(v *Value) {
+	delete(r.ancestors, identity(v))
+}
+
+func (r *rendering) isAncestor(v *Value) bool {
+	return r.ancestors[identity(v)]
+}
+
+func (v *Value) writeJSON(buffer *bytes.Buffer, path string, r *rendering) error {
+	if v == nil {
+		buffer.WriteString("null")
+		return nil
+	}
+	if r.isAncestor(v) {
+		return fmt.Errorf("%v contains itself, JSON can't represent cycles", v.Marker)
+	}
+	switch v.Marker {
+	case Null, Undefined, Unsupported:
+		buffer.WriteString("null")
+	case Number, Boolean, String, LongString, XmlDocument:
+		if number, ok := v.Value.(float64); ok && !isFinite(number) {
+			return fmt.Errorf("number %v can not be represented in JSON", number)
+		}
+		data, err := json.Marshal(v.Value)
+		if err != nil {
+			return err
+		}
+		buffer.Write(data)
+	case Date:
+		t, ok := v.Value.(time.Time)
+		if !ok {
+			return invalidValue(v)
+		}
+		if millis, _ := v.DateMillis(); !isFinite(millis) {
+			return fmt.Errorf("date %v can not be represented in JSON", millis)
+		}
+		data, err := json.Marshal(t.Format(time.RFC3339Nano))
+		if err != nil {
+			return err
+		}
+		buffer.Write(data)
+	case Object, ECMAArray, TypedObject:
+		properties, ok := v.properties()
+		if !ok {
+			return invalidValue(v)
+		}
+		if first, ok := r.enter(v, path); ok {
+			return writeJSONReference(buffer, first)
+		}
+		defer r.leave(v)
+		buffer.WriteByte('{')
+		for i, property := range properties {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			name, err := json.Marshal(property.Name)
+			if err != nil {
+				return err
+			}
+			buffer.Write(name)
+			buffer.WriteByte(':')
+			if err := property.writeJSON(buffer, propertyPath(path, property.Name), r); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte('}')
+	case StrictArray:
+		values, ok := v.Value.([]*Value)
+		if !ok && v.Value != nil {
+			return invalidValue(v)
+		}
+		if first, ok := r.enter(v, path); ok {
+			return writeJSONReference(buffer, first)
+		}
+		defer r.leave(v)
+		buffer.WriteByte('[')
+		for i, arrayValue := range values {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			if err := arrayValue.writeJSON(buffer, elementPath(path, i), r); err != nil {
+				return err
+			}
+		}
+		buffer.WriteByte(']')
+	case AvmPlusObject:
+		data, err := json.Marshal(v.Value)
+		if err != nil {
+			return err
+		}
+		buffer.Write(data)
+	default:
+		return &UnsupportedMarkerError{Marker: v.Marker}
+	}
+	return nil
+}
+
+// writeJSONReference writes the placeholder of a container rendered at the path.
+func writeJSONReference(buffer *bytes.Buffer, path string) error {
+	data, err := json.Marshal(path)
+	if err != nil {
+		return err
+	}
+	buffer.WriteString(`{"$ref":`)
+	buffer.Write(data)
+	buffer.WriteByte('}')
+	return nil
+}
+
+func isFinite(number float64) bool {
+	return !math.IsNaN(number) && !math.IsInf(number, 0)
+}
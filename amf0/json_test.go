@@ -0,0 +1,87 @@
+package amf0_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	data := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("name").String("foo").Name("tags").StrictArray(2, func(b *amf0test.Builder) {
+			b.Number(3).Null()
+		}).Name("again").Reference(1)
+	}).Bytes()
+	value, _, err := amf0.Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	if expected := `{"name":"foo","tags":[3,null],"again":{"$ref":"tags"}}`; string(encoded) != expected {
+		t.Fatalf("got %s, expected %s", encoded, expected)
+	}
+}
+
+func TestMarshalJSONCycle(t *testing.T) {
+	data := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("child").Object(func(b *amf0test.Builder) {
+			b.Name("parent").Reference(0)
+		})
+	}).Bytes()
+	value, _, err := amf0.Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := value.MarshalJSON(); err == nil {
+		t.Fatal("a value containing itself was rendered")
+	}
+}
+
+// sharedReferences returns nested StrictArrays of the depth, every array holds the next one and a Reference to it.
+// Rendering every appearance of the arrays doubles the size with each level.
+func sharedReferences(depth int) []byte {
+	var nest func(b *amf0test.Builder, level int)
+	nest = func(b *amf0test.Builder, level int) {
+		b.StrictArray(2, func(b *amf0test.Builder) {
+			if level == depth {
+				b.Number(1).Number(2)
+				return
+			}
+			// The arrays are the references in the order of their levels
+			nest(b, level+1)
+			b.Reference(uint16(level + 1))
+		})
+	}
+	b := amf0test.New()
+	nest(b, 0)
+	return b.Bytes()
+}
+
+func TestMarshalJSONSharedReferences(t *testing.T) {
+	value, _, err := amf0.Parse(sharedReferences(2))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	encoded, err := value.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	if expected := `[[[1,2],{"$ref":"[0][0]"}],{"$ref":"[0]"}]`; string(encoded) != expected {
+		t.Fatalf("got %s, expected %s", encoded, expected)
+	}
+	value, _, err = amf0.Parse(sharedReferences(30))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if encoded, err = value.MarshalJSON(); err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	if len(encoded) > 4096 {
+		t.Fatalf("rendered %d bytes, expected the shared arrays once", len(encoded))
+	}
+}
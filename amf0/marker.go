@@ -0,0 +1,88 @@
+package amf0
+
+import "fmt"
+
+// RegisterMarker sets fn to read the values of a reserved or unknown marker, which are not read by the parser.
+// The marker is already read and set on v, fn should read the content with ReadBytes or ReadValue and set v.Value.
+// The errors returned by fn are returned by Parse. Panics for the markers read by the parser.
+func (p *Parser) RegisterMarker(marker Marker, fn func(p *Parser, v *Value) error) {
+	switch marker {
+	case Movieclip, Recordset:
+	default:
+		if marker <= AvmPlusObject {
+			panic(fmt.Sprintf("marker %v is read by the parser", marker))
+		}
+	}
+	if p.markers == nil {
+		p.markers = make(map[Marker]func(p *Parser, v *Value) error)
+	}
+	p.markers[marker] = fn
+}
+
+// ReadBytes reads exactly length bytes, for the functions set with RegisterMarker.
+// The limits of the parser apply.
+func (p *Parser) ReadBytes(length int) ([]byte, error) {
+	data, err := p.readBytes(length)
+	if err != nil {
+		return nil, err
+	}
+	// Short reads use the scratch buffer
+	return append([]byte(nil), data...), nil
+}
+
+// ReadValue reads a marker and the value following it, for the functions set with RegisterMarker.
+// The value is nested in the value being read, so it counts for the depth and the references.
+func (p *Parser) ReadValue() (*Value, error) {
+	return p.parseNext()
+}
+
+// PeekMarker reads the marker of the next value, the value can be read with ParseWithMarker after that.
+// The marker is consumed, if the reader had no more values, the error is io.EOF.
+func (p *Parser) PeekMarker() (Marker, error) {
+	p.startValue()
+	marker, err := p.readByte()
+	if err != nil {
+		return 0, fmt.Errorf("offset %d: %w", p.reader.count, err)
+	}
+	return Marker(marker), nil
+}
+
+// ParseWithMarker reads the value of the marker returned by PeekMarker, like Parse would read it.
+func (p *Parser) ParseWithMarker(marker Marker) (*Value, error) {
+	value := &Value{
+		Marker: marker,
+	}
+	if err := p.parseValue(value); err != nil {
+		return nil, fmt.Errorf("offset %d: %w", p.reader.count, err)
+	}
+	return value, nil
+}
+
+var markerNames = map[Marker]string{
+	Number:        "Number",
+	Boolean:       "Boolean",
+	String:        "String",
+	Object:        "Object",
+	Movieclip:     "Movieclip",
+	Null:          "Null",
+	Undefined:     "Undefined",
+	Reference:     "Reference",
+	ECMAArray:     "ECMAArray",
+	ObjectEnd:     "ObjectEnd",
+	StrictArray:   "StrictArray",
+	Date:          "Date",
+	LongString:    "LongString",
+	Unsupported:   "Unsupported",
+	Recordset:     "Recordset",
+	XmlDocument:   "XmlDocument",
+	TypedObject:   "TypedObject",
+	AvmPlusObject: "AvmPlusObject",
+}
+
+// String returns the name of the marker, like "ECMAArray", or "Unknown(0x20)" for unknown markers.
+func (m Marker) String() string {
+	if name, ok := markerNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(0x%02x)", byte(m))
+}
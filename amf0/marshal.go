@@ -0,0 +1,637 @@
+package amf0
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/balazshorvath/goamf/amf3"
+)
+
+var (
+	timeType  = reflect.TypeOf(time.Time{})
+	valueType = reflect.TypeOf(&Value{})
+)
+
+// Marshal returns the AMF0 encoding of v.
+// Structs are encoded as Objects, the exported fields are the properties.
+// The property name can be set with the `amf0:"name"` tag, "-" skips the field.
+// The "omitempty" option, like `amf0:"name,omitempty"`, skips the field if it has a zero number, a false bool,
+// an empty string, map, slice or array, or a nil pointer or interface, like encoding/json.
+// The fields of embedded structs are flattened like by encoding/json, the outer fields win on name collisions.
+// Maps with string keys are encoded as Objects, slices and arrays as StrictArrays.
+// A struct pointer, a map or a slice of the same elements appearing again is written as a Reference,
+// so values containing themselves are encoded too.
+// Numeric types are encoded as Number, strings as String or LongString by length,
+// time.Time as Date, nil as Null. A *Value is encoded as is.
+// Structs registered in the DefaultClassRegistry are encoded as TypedObjects.
+func Marshal(v interface{}) ([]byte, error) {
+	return marshal(DefaultClassRegistry, v)
+}
+
+func marshal(registry *ClassRegistry, v interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	e := NewEncoder(&buffer)
+	e.registry = registry
+	if err := e.Marshal(v); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Marshal writes the AMF0 encoding of v, as described at the package level Marshal.
+// The values are written as they are traversed, without building a Value tree.
+func (e *Encoder) Marshal(v interface{}) error {
+	if err := e.encodeReflect(reflect.ValueOf(v)); err != nil {
+		return fmt.Errorf("offset %d: %w", e.bytesWritten, err)
+	}
+	return nil
+}
+
+// Unmarshal parses the AMF0 encoded data and stores the result in the value pointed to by v.
+// Object properties are matched to the struct fields by the `amf0:"name"` tag or the field name,
+// unknown properties are skipped. Numbers are converted to the numeric kind of the target,
+// numbers not fitting into it are an error. TypedObjects of classes registered in the DefaultClassRegistry
+// are decoded as a pointer to the registered struct when the target is an interface.
+// An Object or array appearing more than once, like the ones read by References, is decoded once
+// into the pointers, slices and maps of the same type, they share it. So a value containing itself can be
+// decoded through them, otherwise it's an error.
+func Unmarshal(data []byte, v interface{}) error {
+	return unmarshal(DefaultClassRegistry, data, v)
+}
+
+func unmarshal(registry *ClassRegistry, data []byte, v interface{}) error {
+	return parseInto(New(bytes.NewReader(data)), registry, v)
+}
+
+// ParseInto reads the next value and stores it in the value pointed to by target, as described at Unmarshal.
+// The errors of Parse are returned as is, like io.EOF, if the reader had no more values.
+func (p *Parser) ParseInto(target interface{}) error {
+	return parseInto(p, DefaultClassRegistry, target)
+}
+
+func parseInto(p *Parser, registry *ClassRegistry, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("unmarshal target must be a non-nil pointer")
+	}
+	value, _, err := p.Parse()
+	if err != nil {
+		return err
+	}
+	c := newConversion(registry)
+	// The pointers to the value in the tree get the target
+	c.decoded[decoded{content: identity(value), typ: rv.Type()}] = rv
+	return c.fromValue(value, rv.Elem())
+}
+
+// field is an exported struct field with it's AMF0 property name.
+type field struct {
+	name   string
+	index  []int
+	tagged bool
+	// Set by the "omitempty" tag option
+	omitEmpty bool
+}
+
+// structFields returns the fields of the struct, the fields of embedded structs without a tag name are
+// flattened into it. Of the fields with the same name, the least nested wins, then the tagged one, then the first.
+func structFields(t reflect.Type) []field {
+	var candidates []field
+	collectFields(t, nil, map[reflect.Type]bool{t: true}, &candidates)
+	best := make(map[string]int, len(candidates))
+	for i, candidate := range candidates {
+		j, ok := best[candidate.name]
+		if !ok || len(candidate.index) < len(candidates[j].index) ||
+			(len(candidate.index) == len(candidates[j].index) && candidate.tagged && !candidates[j].tagged) {
+			best[candidate.name] = i
+		}
+	}
+	var fields []field
+	for i, candidate := range candidates {
+		if best[candidate.name] == i {
+			fields = append(fields, candidate)
+		}
+	}
+	return fields
+}
+
+// collectFields appends the fields of the struct in order, with the index from the outermost struct.
+func collectFields(t reflect.Type, index []int, visiting map[reflect.Type]bool, fields *[]field) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("amf0")
+		if tag == "-" {
+			continue
+		}
+		options := strings.Split(tag, ",")
+		tagName := options[0]
+		fieldIndex := append(append([]int{}, index...), i)
+		if f.Anonymous && tagName == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			// A pointer to an unexported struct can't be allocated
+			if embedded.Kind() == reflect.Struct && (f.PkgPath == "" || f.Type.Kind() != reflect.Ptr) {
+				if !visiting[embedded] {
+					visiting[embedded] = true
+					collectFields(embedded, fieldIndex, visiting, fields)
+					delete(visiting, embedded)
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tagName != "" {
+			name = tagName
+		}
+		omitEmpty := false
+		for _, option := range options[1:] {
+			if option == "omitempty" {
+				omitEmpty = true
+			}
+		}
+		*fields = append(*fields, field{
+			name:      name,
+			index:     fieldIndex,
+			tagged:    tagName != "",
+			omitEmpty: omitEmpty,
+		})
+	}
+}
+
+// fieldByIndex returns the field of the struct. Nil embedded structs are allocated if alloc is set,
+// otherwise the field is not found.
+func fieldByIndex(rv reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
+
+func (e *Encoder) encodeReflect(rv reflect.Value) error {
+	if !rv.IsValid() {
+		return e.writeBytes([]byte{Null})
+	}
+	if rv.Type() == valueType {
+		return e.encodeValue(rv.Interface().(*Value))
+	}
+	if rv.Type() == timeType {
+		return e.encodeValue(NewDate(rv.Interface().(time.Time)))
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return e.writeBytes([]byte{Null})
+		}
+		if rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Struct && rv.Elem().Type() != timeType {
+			// The struct is written once, like the same *Value
+			key := object{pointer: rv.Pointer(), typ: rv.Type()}
+			if index, ok := e.objects[key]; ok {
+				return e.writeReference(index)
+			}
+			e.objects[key] = e.referenceCount
+		}
+		return e.encodeReflect(rv.Elem())
+	case reflect.Bool:
+		return e.encodeValue(NewBool(rv.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return e.encodeValue(NewNumber(float64(rv.Int())))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return e.encodeValue(NewNumber(float64(rv.Uint())))
+	case reflect.Float32, reflect.Float64:
+		return e.encodeValue(NewNumber(rv.Float()))
+	case reflect.String:
+		return e.encodeValue(NewString(rv.String()))
+	case reflect.Struct:
+		fields := structFields(rv.Type())
+		className, typed := e.registry.nameOf(rv.Type())
+		e.referenceCount++
+		if typed {
+			if err := e.writeBytes([]byte{TypedObject}); err != nil {
+				return err
+			}
+			if err := e.writeString(String, className); err != nil {
+				return err
+			}
+		} else if err := e.writeBytes([]byte{Object}); err != nil {
+			return err
+		}
+		for _, f := range fields {
+			fieldValue, ok := fieldByIndex(rv, f.index, false)
+			if !ok || (f.omitEmpty && isEmpty(fieldValue)) {
+				continue
+			}
+			if err := e.encodeReflectProperty(f.name, fieldValue); err != nil {
+				return err
+			}
+		}
+		return e.writeBytes([]byte{0, 0, ObjectEnd})
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map key type %s", rv.Type().Key())
+		}
+		if rv.IsNil() {
+			return e.writeBytes([]byte{Null})
+		}
+		key := object{pointer: rv.Pointer(), typ: rv.Type()}
+		if index, ok := e.objects[key]; ok {
+			return e.writeReference(index)
+		}
+		e.objects[key] = e.referenceCount
+		// Sorted for a deterministic output
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		e.referenceCount++
+		if e.MapsAsECMAArray {
+			if err := e.writeBytes([]byte{ECMAArray}); err != nil {
+				return err
+			}
+			if err := e.writeUint32(uint32(len(keys))); err != nil {
+				return err
+			}
+		} else if err := e.writeBytes([]byte{Object}); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := e.encodeReflectProperty(key.String(), rv.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+		return e.writeBytes([]byte{0, 0, ObjectEnd})
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return e.writeBytes([]byte{Null})
+		}
+		if rv.Kind() == reflect.Slice && rv.Len() > 0 {
+			// The slices of the same elements are written once, like the same *Value
+			key := object{pointer: rv.Pointer(), length: rv.Len(), typ: rv.Type()}
+			if index, ok := e.objects[key]; ok {
+				return e.writeReference(index)
+			}
+			e.objects[key] = e.referenceCount
+		}
+		e.referenceCount++
+		if err := e.writeBytes([]byte{StrictArray}); err != nil {
+			return err
+		}
+		if err := e.writeUint32(uint32(rv.Len())); err != nil {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if err := e.encodeReflect(rv.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported type %s", rv.Type())
+	}
+}
+
+// isEmpty reports whether the field is skipped by the "omitempty" option.
+func isEmpty(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.String, reflect.Map, reflect.Slice, reflect.Array:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// object identifies a struct, a map or a slice written by Marshal, a pointer to the first field of a struct
+// has the same address as the struct, a slice of the first elements of a slice has the same data pointer.
+type object struct {
+	pointer uintptr
+	length  int
+	typ     reflect.Type
+}
+
+func (e *Encoder) encodeReflectProperty(name string, rv reflect.Value) error {
+	if name == "" {
+		return errors.New("property name can not be empty, it would be read as 'ObjectEnd'")
+	}
+	if err := e.writeString(String, name); err != nil {
+		return err
+	}
+	return e.encodeReflect(rv)
+}
+
+// fromValue stores the value in rv. A container is stored once in pointers, slices and maps of the same type,
+// they are shared by it's appearances, which also resolves the containers containing themselves.
+// Storing a container in a struct or an array containing it is an error.
+func (c *conversion) fromValue(value *Value, rv reflect.Value) error {
+	if rv.Type() == valueType {
+		rv.Set(reflect.ValueOf(value))
+		return nil
+	}
+	if value.IsNull() || value.Marker == Undefined || value.Marker == Unsupported {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+	container := value.Marker == Object || value.Marker == ECMAArray || value.Marker == TypedObject ||
+		value.Marker == StrictArray
+	key := decoded{content: identity(value), typ: rv.Type()}
+	if container {
+		switch rv.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map:
+			if shared, ok := c.decoded[key]; ok {
+				rv.Set(shared)
+				return nil
+			}
+		case reflect.Struct, reflect.Array:
+			if c.ancestors[key.content] {
+				return fmt.Errorf("%v contains itself, it can not be stored in %s", value.Marker, rv.Type())
+			}
+			c.ancestors[key.content] = true
+			defer delete(c.ancestors, key.content)
+		}
+	}
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		if container {
+			c.decoded[key] = rv.Elem().Addr()
+		}
+		return c.fromValue(value, rv.Elem())
+	}
+	if rv.Kind() == reflect.Interface && rv.NumMethod() == 0 {
+		native, err := c.toNative(value)
+		if err != nil {
+			return err
+		}
+		if native == nil {
+			rv.Set(reflect.Zero(rv.Type()))
+		} else {
+			rv.Set(reflect.ValueOf(native))
+		}
+		return nil
+	}
+
+	switch value.Marker {
+	case Number:
+		number, ok := value.Value.(float64)
+		if !ok {
+			return invalidValue(value)
+		}
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			// The conversion of a float64 out of the int64 range is undefined
+			if math.IsNaN(number) || number < math.MinInt64 || number >= math.MaxInt64 || rv.OverflowInt(int64(number)) {
+				return fmt.Errorf("number %v overflows %s", number, rv.Type())
+			}
+			rv.SetInt(int64(number))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if math.IsNaN(number) || number < 0 || number >= math.MaxUint64 || rv.OverflowUint(uint64(number)) {
+				return fmt.Errorf("number %v overflows %s", number, rv.Type())
+			}
+			rv.SetUint(uint64(number))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			if !math.IsInf(number, 0) && !math.IsNaN(number) && rv.OverflowFloat(number) {
+				return fmt.Errorf("number %v overflows %s", number, rv.Type())
+			}
+			rv.SetFloat(number)
+			return nil
+		}
+	case Boolean:
+		b, ok := value.Value.(bool)
+		if !ok {
+			return invalidValue(value)
+		}
+		if rv.Kind() == reflect.Bool {
+			rv.SetBool(b)
+			return nil
+		}
+	case String, LongString, XmlDocument:
+		str, ok := value.Value.(string)
+		if !ok {
+			return invalidValue(value)
+		}
+		if rv.Kind() == reflect.String {
+			rv.SetString(str)
+			return nil
+		}
+	case Date:
+		t, ok := value.Value.(time.Time)
+		if !ok {
+			return invalidValue(value)
+		}
+		if rv.Type() == timeType {
+			rv.Set(reflect.ValueOf(t))
+			return nil
+		}
+	case Object, ECMAArray, TypedObject:
+		properties, ok := value.properties()
+		if !ok {
+			return invalidValue(value)
+		}
+		switch rv.Kind() {
+		case reflect.Struct:
+			fields := structFields(rv.Type())
+			for _, property := range properties {
+				for _, f := range fields {
+					if f.name == property.Name {
+						fieldValue, _ := fieldByIndex(rv, f.index, true)
+						if err := c.fromValue(property, fieldValue); err != nil {
+							return err
+						}
+						break
+					}
+				}
+			}
+			return nil
+		case reflect.Map:
+			if rv.Type().Key().Kind() != reflect.String {
+				break
+			}
+			if rv.IsNil() {
+				rv.Set(reflect.MakeMap(rv.Type()))
+			}
+			shared := reflect.New(rv.Type()).Elem()
+			shared.Set(rv)
+			c.decoded[key] = shared
+			for _, property := range properties {
+				element := reflect.New(rv.Type().Elem()).Elem()
+				if err := c.fromValue(property, element); err != nil {
+					return err
+				}
+				rv.SetMapIndex(reflect.ValueOf(property.Name).Convert(rv.Type().Key()), element)
+			}
+			return nil
+		}
+	case StrictArray:
+		values, ok := value.Value.([]*Value)
+		if !ok && value.Value != nil {
+			return invalidValue(value)
+		}
+		switch rv.Kind() {
+		case reflect.Slice:
+			slice := reflect.MakeSlice(rv.Type(), len(values), len(values))
+			c.decoded[key] = slice
+			for i, arrayValue := range values {
+				if err := c.fromValue(arrayValue, slice.Index(i)); err != nil {
+					return err
+				}
+			}
+			rv.Set(slice)
+			return nil
+		case reflect.Array:
+			if rv.Len() < len(values) {
+				return fmt.Errorf("array of length %d does not fit into %s", len(values), rv.Type())
+			}
+			for i, arrayValue := range values {
+				if err := c.fromValue(arrayValue, rv.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot unmarshal %v into %s", value.Marker, rv.Type())
+}
+
+// conversion holds the containers of a converted tree by identity, the ones being converted
+// and the results of the ones already converted, which are shared by their appearances.
+type conversion struct {
+	registry  *ClassRegistry
+	ancestors map[interface{}]bool
+	natives   map[interface{}]interface{}
+	decoded   map[decoded]reflect.Value
+}
+
+// decoded identifies a container stored in a pointer, slice or map of the type.
+type decoded struct {
+	content interface{}
+	typ     reflect.Type
+}
+
+func newConversion(registry *ClassRegistry) *conversion {
+	return &conversion{
+		registry:  registry,
+		ancestors: make(map[interface{}]bool),
+		natives:   make(map[interface{}]interface{}),
+		decoded:   make(map[decoded]reflect.Value),
+	}
+}
+
+// toNative converts the value into plain Go types.
+func (c *conversion) toNative(value *Value) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if t, ok := c.registry.typeOf(value.ClassName); ok && value.Marker == TypedObject {
+		instance := reflect.New(reflect.PtrTo(t)).Elem()
+		if err := c.fromValue(value, instance); err != nil {
+			return nil, err
+		}
+		return instance.Interface(), nil
+	}
+	switch value.Marker {
+	case Object, ECMAArray, TypedObject, StrictArray:
+		key := identity(value)
+		if c.ancestors[key] {
+			return nil, fmt.Errorf("%v contains itself", value.Marker)
+		}
+		if native, ok := c.natives[key]; ok {
+			return native, nil
+		}
+		c.ancestors[key] = true
+		defer delete(c.ancestors, key)
+		native, err := c.containerToNative(value)
+		if err != nil {
+			return nil, err
+		}
+		c.natives[key] = native
+		return native, nil
+	case Null, Undefined, Unsupported:
+		return nil, nil
+	case Number, Boolean, String, LongString, XmlDocument, Date, AvmPlusObject:
+		if !holdsMarkerType(value) {
+			return nil, invalidValue(value)
+		}
+		return value.Value, nil
+	default:
+		return nil, fmt.Errorf("cannot unmarshal %v into interface{}", value.Marker)
+	}
+}
+
+func (c *conversion) containerToNative(value *Value) (interface{}, error) {
+	if value.Marker == StrictArray {
+		values, ok := value.Value.([]*Value)
+		if !ok && value.Value != nil {
+			return nil, invalidValue(value)
+		}
+		result := make([]interface{}, 0, len(values))
+		for _, arrayValue := range values {
+			native, err := c.toNative(arrayValue)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, native)
+		}
+		return result, nil
+	}
+	properties, ok := value.properties()
+	if !ok {
+		return nil, invalidValue(value)
+	}
+	result := make(map[string]interface{}, len(properties))
+	for _, property := range properties {
+		native, err := c.toNative(property)
+		if err != nil {
+			return nil, err
+		}
+		result[property.Name] = native
+	}
+	return result, nil
+}
+
+// holdsMarkerType reports whether the scalar value holds the type of it's marker.
+func holdsMarkerType(value *Value) bool {
+	var ok bool
+	switch value.Marker {
+	case Number:
+		_, ok = value.Value.(float64)
+	case Boolean:
+		_, ok = value.Value.(bool)
+	case String, LongString, XmlDocument:
+		_, ok = value.Value.(string)
+	case Date:
+		_, ok = value.Value.(time.Time)
+	case AvmPlusObject:
+		_, ok = value.Value.(*amf3.Value)
+	}
+	return ok
+}
@@ -0,0 +1,286 @@
+package amf0_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+func TestMarshalTime(t *testing.T) {
+	// The time below milliseconds is kept as a fraction, up to the precision of a double
+	at := time.Date(2020, time.January, 1, 12, 30, 15, 123456789, time.FixedZone("", 3600))
+	data, err := amf0.Marshal(at)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if expected := amf0test.New().Date(1577878215123.456789, 0).Bytes(); !bytes.Equal(data, expected) {
+		t.Fatalf("marshaled % x, expected % x", data, expected)
+	}
+	var got time.Time
+	if err := amf0.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !got.Truncate(time.Millisecond).Equal(at.Truncate(time.Millisecond)) {
+		t.Fatalf("got %v, expected %v to the millisecond", got, at)
+	}
+	if diff := got.Sub(at); diff < -time.Microsecond || diff > time.Microsecond {
+		t.Fatalf("got %v, %v from %v", got, diff, at)
+	}
+}
+
+func TestMarshalOmitEmpty(t *testing.T) {
+	type omitted struct {
+		String     string                 `amf0:"string,omitempty"`
+		Int        int                    `amf0:"int,omitempty"`
+		Uint       uint8                  `amf0:"uint,omitempty"`
+		Float      float64                `amf0:"float,omitempty"`
+		Bool       bool                   `amf0:"bool,omitempty"`
+		Pointer    *int                   `amf0:"pointer,omitempty"`
+		Map        map[string]interface{} `amf0:"map,omitempty"`
+		Slice      []string               `amf0:"slice,omitempty"`
+		EmptySlice []string               `amf0:"emptySlice,omitempty"`
+		Interface  interface{}            `amf0:"interface,omitempty"`
+		Kept       string                 `amf0:"kept"`
+	}
+	data, err := amf0.Marshal(omitted{EmptySlice: []string{}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("kept").String("")
+	}).Bytes()
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("marshaled % x, expected % x", data, expected)
+	}
+	one := 1
+	data, err = amf0.Marshal(omitted{String: "s", Int: -1, Uint: 1, Float: 0.5, Bool: true, Pointer: &one,
+		Map: map[string]interface{}{"a": 1.0}, Slice: []string{"x"}, EmptySlice: []string{"y"}, Interface: "i"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	value, _, err := amf0.Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	properties, err := value.AsMap()
+	if err != nil {
+		t.Fatalf("AsMap failed: %v", err)
+	}
+	if len(properties) != 11 {
+		t.Fatalf("got %d properties, expected the nonzero fields to be kept: %v", len(properties), value)
+	}
+}
+
+func TestUnmarshalSelfReference(t *testing.T) {
+	type Node struct {
+		ID   int
+		Self *Node `amf0:"self"`
+	}
+	data := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("ID").Number(1).Name("self").Reference(0)
+	}).Bytes()
+	var node Node
+	if err := amf0.Unmarshal(data, &node); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if node.ID != 1 || node.Self != &node {
+		t.Fatalf("got %+v, expected the node to reference itself", node)
+	}
+	var pointer *Node
+	if err := amf0.Unmarshal(data, &pointer); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if pointer == nil || pointer.Self != pointer {
+		t.Fatalf("got %+v, expected the node to reference itself", pointer)
+	}
+
+	type List []List
+	var list List
+	if err := amf0.Unmarshal(amf0test.New().StrictArray(1, func(b *amf0test.Builder) {
+		b.Reference(0)
+	}).Bytes(), &list); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(list) != 1 || len(list[0]) != 1 || &list[0][0] != &list[0] {
+		t.Fatalf("got %v, expected the slice to contain itself", list)
+	}
+
+	// Plain maps can't contain themselves
+	var native struct {
+		Self interface{} `amf0:"self"`
+	}
+	if err := amf0.Unmarshal(data, &native); err == nil {
+		t.Fatalf("got %v, expected an error", native)
+	}
+}
+
+func TestUnmarshalSharedReferences(t *testing.T) {
+	var native []interface{}
+	if err := amf0.Unmarshal(sharedReferences(30), &native); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	first, second := native[0].([]interface{}), native[1].([]interface{})
+	if &first[0] != &second[0] {
+		t.Fatal("the Reference was decoded again")
+	}
+	type Node struct {
+		Children []*Node `amf0:"children"`
+	}
+	// Nested Objects of 30 levels, every Object has the next one twice
+	var nest func(b *amf0test.Builder, level int)
+	nest = func(b *amf0test.Builder, level int) {
+		b.Object(func(b *amf0test.Builder) {
+			if level < 30 {
+				b.Name("children").StrictArray(2, func(b *amf0test.Builder) {
+					nest(b, level+1)
+					b.Reference(uint16(2*level + 2))
+				})
+			}
+		})
+	}
+	b := amf0test.New()
+	nest(b, 0)
+	var node Node
+	if err := amf0.Unmarshal(b.Bytes(), &node); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if node.Children[0] != node.Children[1] {
+		t.Fatal("the Reference was decoded again")
+	}
+}
+
+func TestMarshalReferences(t *testing.T) {
+	type node struct {
+		Self *node `amf0:"self"`
+	}
+	cycle := []interface{}{nil}
+	cycle[0] = cycle
+	shared := []int{1, 2}
+	pointer := &node{}
+	pointer.Self = pointer
+	m := map[string]interface{}{}
+	m["self"] = m
+	tests := []struct {
+		name     string
+		v        interface{}
+		expected []byte
+	}{
+		{"slice containing itself", cycle, amf0test.New().StrictArray(1, func(b *amf0test.Builder) {
+			b.Reference(0)
+		}).Bytes()},
+		{"shared slice", [][]int{shared, shared, shared[:1]}, amf0test.New().StrictArray(3, func(b *amf0test.Builder) {
+			b.StrictArray(2, func(b *amf0test.Builder) {
+				b.Number(1).Number(2)
+			}).Reference(1).StrictArray(1, func(b *amf0test.Builder) {
+				b.Number(1)
+			})
+		}).Bytes()},
+		{"struct containing itself", pointer, amf0test.New().Object(func(b *amf0test.Builder) {
+			b.Name("self").Reference(0)
+		}).Bytes()},
+		{"map containing itself", m, amf0test.New().Object(func(b *amf0test.Builder) {
+			b.Name("self").Reference(0)
+		}).Bytes()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := amf0.Marshal(test.v)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if !bytes.Equal(data, test.expected) {
+				t.Fatalf("marshaled % x, expected % x", data, test.expected)
+			}
+		})
+	}
+}
+
+func TestClassRegistry(t *testing.T) {
+	type Point struct {
+		X float64 `amf0:"x"`
+		Y float64 `amf0:"y"`
+	}
+	registry := amf0.NewClassRegistry()
+	registry.Register("flash.geom.Point", Point{})
+	data, err := registry.Marshal([]interface{}{&Point{X: 1, Y: 2}})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := amf0test.New().StrictArray(1, func(b *amf0test.Builder) {
+		b.TypedObject("flash.geom.Point", func(b *amf0test.Builder) {
+			b.Name("x").Number(1).Name("y").Number(2)
+		})
+	}).Bytes()
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("marshaled % x, expected % x", data, expected)
+	}
+	var got interface{}
+	if err := registry.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	elements, ok := got.([]interface{})
+	if !ok || len(elements) != 1 {
+		t.Fatalf("got %#v, expected a slice of the TypedObject", got)
+	}
+	if point, ok := elements[0].(*Point); !ok || *point != (Point{X: 1, Y: 2}) {
+		t.Fatalf("got %#v, expected the registered struct", elements[0])
+	}
+	// Without the registration the TypedObject is a map
+	if err := amf0.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if _, ok := got.([]interface{})[0].(map[string]interface{}); !ok {
+		t.Fatalf("got %#v, expected a map for the unregistered class", got)
+	}
+}
+
+func TestMarshalEmbedded(t *testing.T) {
+	type Base struct {
+		ID   int    `amf0:"id"`
+		Name string `amf0:"name"`
+	}
+	type Outer struct {
+		*Base
+		Name string `amf0:"name"`
+	}
+	// The field of the outer struct wins over the embedded one
+	data, err := amf0.Marshal(Outer{Base: &Base{ID: 1, Name: "inner"}, Name: "outer"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("id").Number(1).Name("name").String("outer")
+	}).Bytes()
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("marshaled % x, expected % x", data, expected)
+	}
+	var got Outer
+	if err := amf0.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.Base == nil || got.ID != 1 || got.Base.Name != "" || got.Name != "outer" {
+		t.Fatalf("got %+v with %+v, expected the embedded struct to be allocated for id", got, got.Base)
+	}
+
+	// The fields of a nil embedded pointer are skipped
+	data, err = amf0.Marshal(Outer{Name: "outer"})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	expected = amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("name").String("outer")
+	}).Bytes()
+	if !bytes.Equal(data, expected) {
+		t.Fatalf("marshaled % x, expected % x", data, expected)
+	}
+	got = Outer{}
+	if err := amf0.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if got.Base != nil || got.Name != "outer" {
+		t.Fatalf("got %+v, expected the embedded pointer to stay nil", got)
+	}
+}
@@ -1,5 +1,17 @@
 package amf0
 
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// UnknownLength is the header and message length, when it's not known.
+const UnknownLength uint32 = math.MaxUint32
+
 // ContextHeader
 // Object references are local to each context header.
 type NCContextHeader struct {
@@ -11,6 +23,7 @@ type NCContextHeader struct {
 }
 
 // Message
+// Object references are local to each message body, they can't point to the values of another message or header.
 type NCMessage struct {
 	TargetUriLength   uint16
 	TargetUri         string
@@ -20,15 +33,242 @@ type NCMessage struct {
 	Body              Value  //
 }
 
+// The versions of the packets. The bodies of both start in AMF0, AMF3 clients switch to AMF3 with AvmPlusObjects.
+const (
+	AMF0Version uint16 = 0
+	AMF3Version uint16 = 3
+)
+
 // Packet represents multiple messages.
-// Version = 0 for AMF0
+// Version is AMF0Version or AMF3Version.
 type NCPacket struct {
 	Version      uint16
 	HeaderCount  uint16
 	MessageCount uint16
 	Headers      []*NCContextHeader
+	Messages     []*NCMessage
+}
+
+// ErrMustUnderstand is matched by the MustUnderstandError with errors.Is.
+var ErrMustUnderstand = errors.New("header must be understood")
+
+// MustUnderstandError is returned for a header with MustUnderstand set, which is not understood by the receiver.
+type MustUnderstandError struct {
+	HeaderName string
+}
+
+func (e *MustUnderstandError) Error() string {
+	return fmt.Sprintf("header %q must be understood", e.HeaderName)
 }
 
+func (e *MustUnderstandError) Unwrap() error {
+	return ErrMustUnderstand
+}
+
+// ParseNetConnectionPacket parses a packet. Every header and message body is parsed with a new parser,
+// because the references are local to them. A known length has to match the length of the body.
+// The version has to be AMF0Version or AMF3Version. The MustUnderstand flags are not checked, see CheckMustUnderstand.
 func ParseNetConnectionPacket(data []byte) (*NCPacket, error) {
-	panic("Not supported")
+	r := &packetReader{data: data}
+	packet := &NCPacket{}
+	var err error
+	if packet.Version, err = r.readUint16(); err != nil {
+		return nil, err
+	}
+	if packet.Version != AMF0Version && packet.Version != AMF3Version {
+		return nil, fmt.Errorf("unsupported packet version %d, expected %d or %d", packet.Version, AMF0Version, AMF3Version)
+	}
+	if packet.HeaderCount, err = r.readUint16(); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(packet.HeaderCount); i++ {
+		header := &NCContextHeader{}
+		if header.NameLength, header.HeaderName, err = r.readString(); err != nil {
+			return nil, err
+		}
+		if header.MustUnderstand, err = r.readUint8(); err != nil {
+			return nil, fmt.Errorf("header %q: %w", header.HeaderName, err)
+		}
+		if header.HeaderLength, err = r.readUint32(); err != nil {
+			return nil, fmt.Errorf("header %q: %w", header.HeaderName, err)
+		}
+		if err := r.readBody(header.HeaderLength, &header.Value); err != nil {
+			return nil, fmt.Errorf("header %q: %w", header.HeaderName, err)
+		}
+		packet.Headers = append(packet.Headers, header)
+	}
+	if packet.MessageCount, err = r.readUint16(); err != nil {
+		return nil, err
+	}
+	for i := 0; i < int(packet.MessageCount); i++ {
+		message := &NCMessage{}
+		if message.TargetUriLength, message.TargetUri, err = r.readString(); err != nil {
+			return nil, err
+		}
+		if message.ResponseUriLength, message.ResponseUri, err = r.readString(); err != nil {
+			return nil, fmt.Errorf("message %q: %w", message.TargetUri, err)
+		}
+		if message.MessageLength, err = r.readUint32(); err != nil {
+			return nil, fmt.Errorf("message %q: %w", message.TargetUri, err)
+		}
+		if err := r.readBody(message.MessageLength, &message.Body); err != nil {
+			return nil, fmt.Errorf("message %q: %w", message.TargetUri, err)
+		}
+		packet.Messages = append(packet.Messages, message)
+	}
+	return packet, nil
+}
+
+// CheckMustUnderstand returns a MustUnderstandError for the first header with MustUnderstand set,
+// which is not one of the understood header names. Other headers can be skipped by the receiver.
+func (p *NCPacket) CheckMustUnderstand(understood ...string) error {
+	for _, header := range p.Headers {
+		if header.MustUnderstand == 0 {
+			continue
+		}
+		found := false
+		for _, name := range understood {
+			if name == header.HeaderName {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &MustUnderstandError{HeaderName: header.HeaderName}
+		}
+	}
+	return nil
+}
+
+// Encode serializes the packet. The name and count fields are written based on the actual data.
+// The header and message lengths are written as UnknownLength if they are set to it,
+// otherwise the length of the encoded value is written.
+func (p *NCPacket) Encode() ([]byte, error) {
+	if len(p.Headers) > math.MaxUint16 || len(p.Messages) > math.MaxUint16 {
+		return nil, fmt.Errorf("too many headers (%d) or messages (%d)", len(p.Headers), len(p.Messages))
+	}
+	var buffer bytes.Buffer
+	e := NewEncoder(&buffer)
+	if err := e.writeUint16(p.Version); err != nil {
+		return nil, err
+	}
+	if err := e.writeUint16(uint16(len(p.Headers))); err != nil {
+		return nil, err
+	}
+	for _, header := range p.Headers {
+		if err := e.writeString(String, header.HeaderName); err != nil {
+			return nil, fmt.Errorf("header %q: %w", header.HeaderName, err)
+		}
+		if err := e.writeBytes([]byte{header.MustUnderstand}); err != nil {
+			return nil, err
+		}
+		if err := e.writeBody(&header.Value, header.HeaderLength); err != nil {
+			return nil, fmt.Errorf("header %q: %w", header.HeaderName, err)
+		}
+	}
+	if err := e.writeUint16(uint16(len(p.Messages))); err != nil {
+		return nil, err
+	}
+	for _, message := range p.Messages {
+		if err := e.writeString(String, message.TargetUri); err != nil {
+			return nil, fmt.Errorf("message %q: %w", message.TargetUri, err)
+		}
+		if err := e.writeString(String, message.ResponseUri); err != nil {
+			return nil, fmt.Errorf("message %q: %w", message.TargetUri, err)
+		}
+		if err := e.writeBody(&message.Body, message.MessageLength); err != nil {
+			return nil, fmt.Errorf("message %q: %w", message.TargetUri, err)
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+// packetReader reads the fields of a packet.
+type packetReader struct {
+	data   []byte
+	offset int
+}
+
+func (r *packetReader) read(length int) ([]byte, error) {
+	if length > len(r.data)-r.offset {
+		return nil, fmt.Errorf("offset %d: %w reading %d bytes", r.offset, io.ErrUnexpectedEOF, length)
+	}
+	data := r.data[r.offset : r.offset+length]
+	r.offset += length
+	return data, nil
+}
+
+func (r *packetReader) readUint8() (uint8, error) {
+	data, err := r.read(1)
+	if err != nil {
+		return 0, err
+	}
+	return data[0], nil
+}
+
+func (r *packetReader) readUint16() (uint16, error) {
+	data, err := r.read(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint16(data), nil
+}
+
+func (r *packetReader) readUint32() (uint32, error) {
+	data, err := r.read(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(data), nil
+}
+
+func (r *packetReader) readString() (uint16, string, error) {
+	length, err := r.readUint16()
+	if err != nil {
+		return 0, "", err
+	}
+	data, err := r.read(int(length))
+	if err != nil {
+		return 0, "", err
+	}
+	return length, string(data), nil
+}
+
+// readBody parses the value with a new parser, so the AMF0 and AMF3 reference tables start empty for every body.
+// The value is read into the Value of the header or message, so the References to it resolve to that.
+// A known length has to match the length of the value.
+func (r *packetReader) readBody(length uint32, value *Value) error {
+	data := r.data[r.offset:]
+	if length != UnknownLength {
+		if int64(length) > int64(len(data)) {
+			return fmt.Errorf("offset %d: %w reading body of %d bytes", r.offset, io.ErrUnexpectedEOF, length)
+		}
+		data = data[:length]
+	}
+	p := New(bytes.NewReader(data))
+	p.startValue()
+	if err := p.parseNextInto(value); err != nil {
+		return fmt.Errorf("offset %d in body: %w", r.offset+p.reader.count, err)
+	}
+	bytesRead := p.reader.count
+	if length != UnknownLength && bytesRead != int(length) {
+		return fmt.Errorf("body at offset %d: declared %d bytes, but the value has %d", r.offset, length, bytesRead)
+	}
+	r.offset += bytesRead
+	return nil
+}
+
+// writeBody writes the length and the value, with a new encoder, because the references are local to the body.
+func (e *Encoder) writeBody(value *Value, length uint32) error {
+	var body bytes.Buffer
+	if err := NewEncoder(&body).Encode(value); err != nil {
+		return err
+	}
+	if length != UnknownLength {
+		length = uint32(body.Len())
+	}
+	if err := e.writeUint32(length); err != nil {
+		return err
+	}
+	return e.writeBytes(body.Bytes())
 }
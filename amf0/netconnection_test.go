@@ -0,0 +1,47 @@
+package amf0_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+// message appends a message with the target uri and the body.
+func message(b *amf0test.Builder, target string, body []byte) *amf0test.Builder {
+	return b.Name(target).Name("/1").Uint32(uint32(len(body))).Raw(body...)
+}
+
+func TestParseNetConnectionPacketReferences(t *testing.T) {
+	body := func(id float64) []byte {
+		return amf0test.New().Object(func(b *amf0test.Builder) {
+			b.Name("id").Number(id).Name("self").Reference(0)
+		}).Bytes()
+	}
+	b := amf0test.New().Uint16(amf0.AMF0Version).Uint16(0).Uint16(2)
+	message(b, "first", body(1))
+	message(b, "second", body(2))
+	data := b.Bytes()
+	packet, err := amf0.ParseNetConnectionPacket(data)
+	if err != nil {
+		t.Fatalf("ParseNetConnectionPacket failed: %v", err)
+	}
+	for i, message := range packet.Messages {
+		self, ok := message.Body.Property("self")
+		if !ok {
+			t.Fatalf("message %d has no self property: %v", i, &message.Body)
+		}
+		// The Reference of the second message can't point to the first one
+		if id, ok := self.Property("id"); !ok || id.Value != float64(i+1) {
+			t.Errorf("message %d refers to %v, expected the id %d", i, self, i+1)
+		}
+	}
+	encoded, err := packet.Encode()
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !bytes.Equal(encoded, data) {
+		t.Fatalf("encoded % x, expected % x", encoded, data)
+	}
+}
@@ -0,0 +1,55 @@
+package amf0
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// RawValue is the encoded form of a value with it's marker. The properties selected by Parser.RawProperty
+// have it as their Value, with the Marker of the encoded value. The encoder writes it as it is, so
+// it can be forwarded untouched, or parsed later with Parse. A RawValue containing References to values
+// outside of it is only valid at the same position of the same stream. The AMF3 reference tables of the encoder
+// don't get the values of a RawValue, so it should not have AvmPlusObjects followed by encoded AvmPlusObjects.
+type RawValue []byte
+
+// readRaw reads the value of the property without building it, keeping the bytes it was read from.
+// The containers in it get placeholders in the reference table, like for the skipped values.
+func (p *Parser) readRaw(marker Marker, name string) (*Value, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte(byte(marker))
+	p.reader.capture = &buffer
+	err := p.skipValue(marker)
+	p.reader.capture = nil
+	if err != nil {
+		return nil, err
+	}
+	return &Value{
+		Marker:     marker,
+		Name:       name,
+		Value:      RawValue(buffer.Bytes()),
+		ByteLength: buffer.Len(),
+	}, nil
+}
+
+// encodeRaw writes the raw value. The containers in it are counted for the reference table,
+// by reading it after the values already in the table.
+func (e *Encoder) encodeRaw(raw RawValue) error {
+	if len(raw) == 0 {
+		return errors.New("raw value is empty")
+	}
+	// The marker is read by skipValue's caller
+	p := New(bytes.NewReader(raw[1:]))
+	p.MaxReferences = 0
+	p.references = make([]*Value, minInt(e.referenceCount, math.MaxUint16+1))
+	p.referenceCount = e.referenceCount
+	if err := p.skipValue(Marker(raw[0])); err != nil {
+		return fmt.Errorf("raw value: %w", err)
+	}
+	if p.reader.count != len(raw)-1 {
+		return fmt.Errorf("raw value has %d bytes after the value", len(raw)-1-p.reader.count)
+	}
+	e.referenceCount = p.referenceCount
+	return e.writeBytes(raw)
+}
@@ -0,0 +1,121 @@
+package amf0
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// countingReader counts the bytes read from the reader, the count is the offset used by the parser.
+type countingReader struct {
+	reader     io.Reader
+	byteReader io.ByteReader // Set, if the reader implements it
+	seeker     io.Seeker     // Set, if the reader implements it
+	deadliner  deadliner     // Set, if the reader implements it
+	// The read deadline is set to this from the time of every read, see Parser.ReadTimeout
+	timeout time.Duration
+	count   int
+	scratch [1]byte
+	// The bytes read are copied here, if it's set
+	capture *bytes.Buffer
+	// The bytes put back by unread, they are read first
+	pending []byte
+}
+
+// reset continues with the reader, counting from 0.
+func (r *countingReader) reset(reader io.Reader) {
+	r.reader = reader
+	r.byteReader, _ = reader.(io.ByteReader)
+	r.seeker, _ = reader.(io.Seeker)
+	r.deadliner, _ = reader.(deadliner)
+	r.count = 0
+	r.pending = nil
+}
+
+func (r *countingReader) Read(buffer []byte) (int, error) {
+	if len(r.pending) > 0 {
+		n := copy(buffer, r.pending)
+		r.pending = r.pending[n:]
+		r.count += n
+		if r.capture != nil {
+			r.capture.Write(buffer[:n])
+		}
+		return n, nil
+	}
+	if err := r.extendDeadline(); err != nil {
+		return 0, err
+	}
+	n, err := r.reader.Read(buffer)
+	r.count += n
+	if r.capture != nil {
+		r.capture.Write(buffer[:n])
+	}
+	return n, err
+}
+
+// ReadByte reads a single byte, with ReadByte if the reader supports it.
+func (r *countingReader) ReadByte() (byte, error) {
+	if r.byteReader == nil || len(r.pending) > 0 {
+		_, err := io.ReadFull(r, r.scratch[:])
+		return r.scratch[0], err
+	}
+	if err := r.extendDeadline(); err != nil {
+		return 0, err
+	}
+	b, err := r.byteReader.ReadByte()
+	if err == nil {
+		r.count++
+		if r.capture != nil {
+			r.capture.WriteByte(b)
+		}
+	}
+	return b, err
+}
+
+// extendDeadline sets the read deadline for the next read, if there's a timeout.
+func (r *countingReader) extendDeadline() error {
+	if r.timeout <= 0 || r.deadliner == nil {
+		return nil
+	}
+	return r.deadliner.SetReadDeadline(time.Now().Add(r.timeout))
+}
+
+// remaining returns the amount of bytes left, if the reader knows it, like a bytes.Reader,
+// or an io.LimitedReader, like the frame of ParseFrame.
+func (r *countingReader) remaining() (int, bool) {
+	switch reader := r.reader.(type) {
+	case interface{ Len() int }:
+		return reader.Len() + len(r.pending), true
+	case *io.LimitedReader:
+		if reader.N > math.MaxInt32 {
+			return 0, false
+		}
+		return int(reader.N) + len(r.pending), true
+	}
+	return 0, false
+}
+
+// unread puts back the bytes just read, so they are read again. The count is moved back too.
+func (r *countingReader) unread(data []byte) {
+	r.pending = append(append([]byte(nil), data...), r.pending...)
+	r.count -= len(data)
+	if r.capture != nil {
+		r.capture.Truncate(r.capture.Len() - len(data))
+	}
+}
+
+// seek moves the reader to the offset from it's start, the count is set to the offset.
+func (r *countingReader) seek(offset int64) error {
+	if r.seeker == nil {
+		return errors.New("the reader does not implement io.Seeker")
+	}
+	position, err := r.seeker.Seek(offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	r.count = int(position)
+	r.pending = nil
+	return nil
+}
@@ -0,0 +1,95 @@
+package amf0
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RecoveredError is the Value of the Unsupported value, which replaces the properties or elements dropped
+// by Parser.Recover. Offset is where the corrupt value started, Skipped is the amount of bytes dropped.
+//
+// For an Object, ECMAArray or TypedObject, the parser continues after the next 'ObjectEnd' sequence,
+// which may belong to a nested object. A StrictArray has no terminator, so the end of it's elements
+// can't be found after a corrupt one. The closest Object, ECMAArray or TypedObject containing it is recovered
+// instead, from the property holding the array, or Parse returns the error, if there's no such object,
+// so the elements left are not read as values. Running out of data, exceeding the limits and
+// the context are not recovered from.
+type RecoveredError struct {
+	Offset  int
+	Skipped int
+	Err     error
+}
+
+func (e *RecoveredError) Error() string {
+	return fmt.Sprintf("dropped %d bytes at offset %d: %v", e.Skipped, e.Offset, e.Err)
+}
+
+func (e *RecoveredError) Unwrap() error {
+	return e.Err
+}
+
+// recoverable reports whether Recover applies to the error.
+func (p *Parser) recoverable(err error) bool {
+	if !p.Recover {
+		return false
+	}
+	for _, unrecoverable := range []error{
+		io.EOF, io.ErrUnexpectedEOF, ErrMaxBytesExceeded, ErrMaxDepthExceeded, ErrMaxReferencesExceeded,
+		context.Canceled, context.DeadlineExceeded,
+	} {
+		if errors.Is(err, unrecoverable) {
+			return false
+		}
+	}
+	return true
+}
+
+// recovered returns the value replacing the dropped values from offset and reports it to OnWarning.
+func (p *Parser) recovered(name string, offset int, err error) *Value {
+	recovered := &RecoveredError{
+		Offset:  offset,
+		Skipped: p.reader.count - offset,
+		Err:     err,
+	}
+	p.warn(offset, "%v", recovered)
+	return &Value{
+		Marker: Unsupported,
+		Name:   name,
+		Value:  recovered,
+	}
+}
+
+// recoverProperties drops the rest of the properties from offset if the error is recoverable,
+// by reading until the next 'ObjectEnd'. The name is empty for a corrupt 'ObjectEnd'.
+func (p *Parser) recoverProperties(properties []*Value, count int, name string, offset int, err error) ([]*Value, int, error) {
+	if !p.recoverable(err) {
+		return nil, count, err
+	}
+	zeros := 0
+	for {
+		b, readErr := p.readByte()
+		if readErr != nil {
+			return nil, count, err
+		}
+		if b == ObjectEnd && zeros >= 2 {
+			break
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+	return append(properties, p.recovered(name, offset, err)), count, nil
+}
+
+// isRecovered reports whether the properties end with a value replacing the dropped ones.
+func isRecovered(properties []*Value) bool {
+	if len(properties) == 0 {
+		return false
+	}
+	_, ok := properties[len(properties)-1].Value.(*RecoveredError)
+	return ok
+}
@@ -0,0 +1,87 @@
+package amf0_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+func TestRecoverProperty(t *testing.T) {
+	// The Reference is out of the reference table
+	data := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("a").Number(1).Name("b").Reference(99).Name("c").Number(2)
+	}).Number(3).Bytes()
+	if _, _, err := amf0.New(bytes.NewReader(data)).ParseAll(); !errors.Is(err, amf0.ErrInvalidReference) {
+		t.Fatalf("got error %v without Recover, expected amf0.ErrInvalidReference", err)
+	}
+	p := amf0.New(bytes.NewReader(data))
+	p.Recover = true
+	var warnings []string
+	p.OnWarning = func(offset int, warning string) {
+		warnings = append(warnings, warning)
+	}
+	values, _, err := p.ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll failed: %v", err)
+	}
+	if len(values) != 2 || values[1].Value != 3.0 {
+		t.Fatalf("got %v, expected the Object and the Number after it", values)
+	}
+	properties := values[0].Value.([]*amf0.Value)
+	if len(properties) != 2 || properties[0].Value != 1.0 {
+		t.Fatalf("got %v, expected the first property and the dropped ones", values[0])
+	}
+	var recovered *amf0.RecoveredError
+	if !errors.As(properties[1].Value.(error), &recovered) || properties[1].Marker != amf0.Unsupported ||
+		properties[1].Name != "b" {
+		t.Fatalf("got %v, expected the dropped properties from b", properties[1])
+	}
+	// The marker of b is at 16, the rest of the Object is dropped with the 'ObjectEnd'
+	if recovered.Offset != 16 || recovered.Skipped != len(data)-9-16 || !errors.Is(recovered, amf0.ErrInvalidReference) {
+		t.Fatalf("got %+v", recovered)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("got the warnings %q, expected one", warnings)
+	}
+}
+
+func TestRecoverStrictArray(t *testing.T) {
+	array := func(b *amf0test.Builder) {
+		b.Number(1).Reference(99).Number(3)
+	}
+	t.Run("top level", func(t *testing.T) {
+		p := amf0.New(bytes.NewReader(amf0test.New().StrictArray(3, array).Number(4).Bytes()))
+		p.Recover = true
+		values, _, err := p.ParseAll()
+		if !errors.Is(err, amf0.ErrInvalidReference) {
+			t.Fatalf("got error %v, expected amf0.ErrInvalidReference", err)
+		}
+		if len(values) != 0 {
+			t.Fatalf("got %v, expected the elements left not to be read as values", values)
+		}
+	})
+	t.Run("in an Object", func(t *testing.T) {
+		data := amf0test.New().Object(func(b *amf0test.Builder) {
+			b.Name("a").StrictArray(3, array).Name("b").Number(4)
+		}).Number(5).Bytes()
+		p := amf0.New(bytes.NewReader(data))
+		p.Recover = true
+		values, _, err := p.ParseAll()
+		if err != nil {
+			t.Fatalf("ParseAll failed: %v", err)
+		}
+		if len(values) != 2 || values[1].Value != 5.0 {
+			t.Fatalf("got %v, expected the Object and the Number after it", values)
+		}
+		properties := values[0].Value.([]*amf0.Value)
+		if len(properties) != 1 || properties[0].Name != "a" {
+			t.Fatalf("got %v, expected the Object to be dropped from the array", values[0])
+		}
+		if _, ok := properties[0].Value.(*amf0.RecoveredError); !ok {
+			t.Fatalf("got %v, expected a RecoveredError", properties[0])
+		}
+	})
+}
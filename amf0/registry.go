@@ -0,0 +1,81 @@
+package amf0
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// ClassRegistry maps TypedObject class names to Go struct types.
+// Registered structs are encoded as TypedObjects with their class name,
+// and TypedObjects of a registered class are decoded into a new instance of the struct
+// when the target is an interface.
+type ClassRegistry struct {
+	mutex   sync.RWMutex
+	classes map[string]reflect.Type
+	names   map[reflect.Type]string
+}
+
+// DefaultClassRegistry is used by Marshal, Unmarshal and Register.
+var DefaultClassRegistry = NewClassRegistry()
+
+func NewClassRegistry() *ClassRegistry {
+	return &ClassRegistry{
+		classes: make(map[string]reflect.Type),
+		names:   make(map[reflect.Type]string),
+	}
+}
+
+// Register registers the class name for the type of the prototype in the DefaultClassRegistry.
+func Register(className string, prototype interface{}) {
+	DefaultClassRegistry.Register(className, prototype)
+}
+
+// Register registers the class name for the type of the prototype, which has to be a struct or a pointer to a struct.
+// Panics if the prototype is not a struct or the class name is empty.
+func (r *ClassRegistry) Register(className string, prototype interface{}) {
+	t := reflect.TypeOf(prototype)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("class %q: prototype must be a struct, got %T", className, prototype))
+	}
+	if className == "" {
+		panic(fmt.Sprintf("class name for %s can not be empty", t))
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.classes[className] = t
+	r.names[t] = className
+}
+
+// Marshal is like the package level Marshal, using the registry.
+func (r *ClassRegistry) Marshal(v interface{}) ([]byte, error) {
+	return marshal(r, v)
+}
+
+// Unmarshal is like the package level Unmarshal, using the registry.
+func (r *ClassRegistry) Unmarshal(data []byte, v interface{}) error {
+	return unmarshal(r, data, v)
+}
+
+func (r *ClassRegistry) typeOf(className string) (reflect.Type, bool) {
+	if r == nil {
+		return nil, false
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	t, ok := r.classes[className]
+	return t, ok
+}
+
+func (r *ClassRegistry) nameOf(t reflect.Type) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	name, ok := r.names[t]
+	return name, ok
+}
@@ -0,0 +1,102 @@
+package amf0
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrSchemaMismatch is matched by the errors of Validate with errors.Is.
+var ErrSchemaMismatch = errors.New("schema mismatch")
+
+// Schema describes the expected structure of a value for Validate.
+// Markers are the accepted markers, any marker is accepted if it's empty. A nil value is a Null.
+// ClassName is the class name of a TypedObject, any class name is accepted if it's empty.
+// Properties are checked for Objects, ECMAArrays and TypedObjects in order, other properties are allowed.
+// Elements is the schema of every element of a StrictArray, the elements are not checked if it's nil.
+type Schema struct {
+	Markers    []Marker
+	ClassName  string
+	Properties []PropertySchema
+	Elements   *Schema
+}
+
+// PropertySchema is the schema of the first property with the name, a missing property is an error,
+// unless it's Optional.
+type PropertySchema struct {
+	Name     string
+	Optional bool
+	Schema   Schema
+}
+
+// SchemaError is returned by Validate for the first value not matching it's schema,
+// Path is the path of the value like in Walk. It matches ErrSchemaMismatch with errors.Is.
+type SchemaError struct {
+	Path   string
+	Reason string
+}
+
+func (e *SchemaError) Error() string {
+	if e.Path == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Reason)
+}
+
+func (e *SchemaError) Unwrap() error {
+	return ErrSchemaMismatch
+}
+
+// Validate checks the tree against the schema, returning a *SchemaError for the first mismatch
+// in the order of Walk.
+func Validate(v *Value, schema Schema) error {
+	return validate("", v, &schema)
+}
+
+func validate(path string, v *Value, schema *Schema) error {
+	marker := v.marker()
+	if len(schema.Markers) > 0 && !containsMarker(schema.Markers, marker) {
+		return &SchemaError{Path: path, Reason: fmt.Sprintf("expected %v, got %v", schema.Markers, marker)}
+	}
+	if schema.ClassName != "" && marker == TypedObject && v.ClassName != schema.ClassName {
+		return &SchemaError{Path: path, Reason: fmt.Sprintf("expected class %q, got %q", schema.ClassName, v.ClassName)}
+	}
+	if len(schema.Properties) > 0 {
+		if _, ok := v.properties(); !ok {
+			return &SchemaError{Path: path, Reason: fmt.Sprintf("%v has no properties", marker)}
+		}
+		for i := range schema.Properties {
+			property := &schema.Properties[i]
+			value, ok := v.Property(property.Name)
+			if !ok {
+				if property.Optional {
+					continue
+				}
+				return &SchemaError{Path: path, Reason: fmt.Sprintf("missing property %q", property.Name)}
+			}
+			if err := validate(propertyPath(path, property.Name), value, &property.Schema); err != nil {
+				return err
+			}
+		}
+	}
+	if schema.Elements != nil {
+		values, ok := v.Value.([]*Value)
+		if marker != StrictArray || (!ok && v.Value != nil) {
+			return &SchemaError{Path: path, Reason: fmt.Sprintf("%v has no elements", marker)}
+		}
+		for i, arrayValue := range values {
+			if err := validate(elementPath(path, i), arrayValue, schema.Elements); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func containsMarker(markers []Marker, marker Marker) bool {
+	for _, m := range markers {
+		if m == marker {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package amf0
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// ParseFiltered is like Parse, but only the properties kept by the filter are stored of the Object,
+// ECMAArray or TypedObject read, the nested values are not filtered. The other properties are read
+// without building their values, so they don't get into the reference table either,
+// a Reference to them is an error.
+func (p *Parser) ParseFiltered(keep func(name string) bool) (*Value, int, error) {
+	p.keep = keep
+	defer func() {
+		p.keep = nil
+	}()
+	return p.Parse()
+}
+
+// Skip reads the next value without building it, returning the amount of bytes of it. The containers in it
+// get placeholders in the reference table, so a Reference to them is an error, like for ParseFiltered.
+// AvmPlusObjects and the markers set with RegisterMarker are still read as values.
+// If the reader had no more values, the error is io.EOF, a value ending early results in io.ErrUnexpectedEOF.
+func (p *Parser) Skip() (int, error) {
+	p.startValue()
+	start := p.reader.count
+	marker, err := p.readByte()
+	if err == nil {
+		err = p.skipValue(Marker(marker))
+	}
+	if err != nil {
+		return p.reader.count - start, fmt.Errorf("offset %d: %w", p.reader.count, err)
+	}
+	return p.reader.count - start, nil
+}
+
+// skipValue reads the value of the marker without storing it. Only the structure of the value is checked,
+// the containers get placeholders in the reference table.
+func (p *Parser) skipValue(marker Marker) error {
+	p.depth++
+	previous := p.marker
+	p.marker = marker
+	defer func() {
+		p.depth--
+		p.marker = previous
+	}()
+	if p.MaxDepth > 0 && p.depth > p.MaxDepth {
+		return fmt.Errorf("%w: %d", ErrMaxDepthExceeded, p.MaxDepth)
+	}
+	if err := p.checkContext(); err != nil {
+		return err
+	}
+	switch marker {
+	case Number:
+		return p.discard(8)
+	case Boolean:
+		return p.discard(1)
+	case Date:
+		return p.discard(10)
+	case String, LongString, XmlDocument:
+		return p.skipString(marker)
+	case Null, Undefined, Unsupported:
+		return nil
+	case Reference:
+		_, err := p.readReferenceIndex()
+		return err
+	case Object, ECMAArray, TypedObject:
+		limit := -1
+		if marker == ECMAArray {
+			data, err := p.readBytes(4)
+			if err != nil {
+				return err
+			}
+			limit = p.countedLength(p.byteOrder().Uint32(data))
+		} else if marker == TypedObject {
+			if err := p.skipString(String); err != nil {
+				return err
+			}
+		}
+		if err := p.addReference(nil); err != nil {
+			return err
+		}
+		return p.skipProperties(limit)
+	case StrictArray:
+		data, err := p.readBytes(4)
+		if err != nil {
+			return err
+		}
+		length := int(p.byteOrder().Uint32(data))
+		if err := p.checkArrayLength(length); err != nil {
+			return err
+		}
+		if err := p.addReference(nil); err != nil {
+			return err
+		}
+		for i := 0; i < length; i++ {
+			elementMarker, err := p.readByte()
+			if err != nil {
+				return err
+			}
+			if err := p.skipValue(Marker(elementMarker)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ObjectEnd:
+		return errUnexpectedObjectEnd
+	case AvmPlusObject:
+		// The AMF3 reference tables have to be kept
+		_, err := p.parseAMF3()
+		return err
+	default:
+		// The registered markers can only be read by their functions
+		if _, ok := p.markers[marker]; ok || marker == Recordset || marker == Movieclip {
+			p.depth--
+			err := p.parseValue(&Value{Marker: marker})
+			p.depth++
+			return err
+		}
+		if p.Strict {
+			return &UnsupportedMarkerError{Marker: marker}
+		}
+		p.warn(p.reader.count-1, "unknown marker 0x%02x", byte(marker))
+		return nil
+	}
+}
+
+// skipProperties reads the properties like parseProperties without storing them.
+func (p *Parser) skipProperties(limit int) error {
+	for count := 0; ; count++ {
+		if count == limit {
+			return p.readCountedEnd(count)
+		}
+		data, err := p.readBytes(2)
+		if err != nil {
+			return unterminatedError(err)
+		}
+		nameLength := int(p.byteOrder().Uint16(data))
+		if err := p.discard(nameLength); err != nil {
+			return unterminatedError(err)
+		}
+		marker, err := p.readByte()
+		if err != nil {
+			return unterminatedError(err)
+		}
+		// Check if 'ObjectEnd'
+		if nameLength == 0 {
+			if marker != ObjectEnd {
+				return objectEndError(marker)
+			}
+			return nil
+		}
+		if err := p.skipValue(Marker(marker)); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *Parser) skipString(marker Marker) error {
+	length, err := p.readStringLength(marker)
+	if err != nil {
+		return err
+	}
+	return p.discard(length)
+}
+
+// discard reads length bytes without storing them.
+func (p *Parser) discard(length int) error {
+	if length <= len(p.scratch) {
+		_, err := p.readBytes(length)
+		return err
+	}
+	if err := p.checkMaxBytes(length); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(ioutil.Discard, &p.reader, int64(length)); err != nil {
+		return p.readError(err, length)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package amf0
+
+import (
+	"context"
+	"fmt"
+)
+
+// DecodeArrayStream reads the next value, which has to be a StrictArray, sending the elements on the values channel
+// as they are read. The channel is closed after the last element, or an error, which is sent on the errors channel.
+// The errors channel is closed after that, it has no value if the array was read. The values are not buffered,
+// the reading waits for the elements to be received, so the values channel has to be drained, or ctx canceled
+// by a consumer stopping early. When ctx is done, the reading stops with it's error, which is checked before every
+// value and while waiting for the consumer, like by ParseContext, a pending read is not interrupted.
+// The parser should not be used until the channels are closed.
+// The array itself is not kept, a Reference to it is an error like for the skipped values.
+func (p *Parser) DecodeArrayStream(ctx context.Context) (<-chan *Value, <-chan error) {
+	values := make(chan *Value)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(values)
+		p.ctx = ctx
+		defer func() {
+			p.ctx = nil
+		}()
+		if err := p.decodeArrayStream(ctx, values); err != nil {
+			errs <- fmt.Errorf("offset %d: %w", p.reader.count, err)
+		}
+	}()
+	return values, errs
+}
+
+func (p *Parser) decodeArrayStream(ctx context.Context, values chan<- *Value) error {
+	p.startValue()
+	marker, err := p.readByte()
+	if err != nil {
+		return err
+	}
+	if Marker(marker) != StrictArray {
+		return fmt.Errorf("expected %v, got marker %v", Marker(StrictArray), Marker(marker))
+	}
+	p.depth++
+	previous := p.marker
+	p.marker = StrictArray
+	defer func() {
+		p.depth--
+		p.marker = previous
+	}()
+	data, err := p.readBytes(4)
+	if err != nil {
+		return err
+	}
+	length := int(p.byteOrder().Uint32(data))
+	if err := p.checkArrayLength(length); err != nil {
+		return err
+	}
+	if err := p.addReference(nil); err != nil {
+		return err
+	}
+	for i := 0; i < length; i++ {
+		value, err := p.parseNext()
+		if err != nil {
+			return err
+		}
+		select {
+		case values <- value:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
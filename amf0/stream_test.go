@@ -0,0 +1,42 @@
+package amf0_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+func TestDecodeArrayStream(t *testing.T) {
+	data := amf0test.New().StrictArray(3, func(b *amf0test.Builder) {
+		b.Number(1).String("foo").Boolean(true)
+	}).Bytes()
+	values, errs := amf0.New(bytes.NewReader(data)).DecodeArrayStream(context.Background())
+	var markers []amf0.Marker
+	for value := range values {
+		markers = append(markers, value.Marker)
+	}
+	if err := <-errs; err != nil {
+		t.Fatalf("DecodeArrayStream failed: %v", err)
+	}
+	if len(markers) != 3 || markers[0] != amf0.Number || markers[1] != amf0.String || markers[2] != amf0.Boolean {
+		t.Fatalf("got the markers %v", markers)
+	}
+}
+
+func TestDecodeArrayStreamCancel(t *testing.T) {
+	data := amf0test.New().StrictArray(3, func(b *amf0test.Builder) {
+		b.Number(1).Number(2).Number(3)
+	}).Bytes()
+	ctx, cancel := context.WithCancel(context.Background())
+	values, errs := amf0.New(bytes.NewReader(data)).DecodeArrayStream(ctx)
+	<-values
+	cancel()
+	// The reading stops without the rest of the values being received
+	if err := <-errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, expected context.Canceled", err)
+	}
+}
@@ -0,0 +1,129 @@
+package amf0
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// String renders the value tree for debugging purposes, one property or element per line, like
+//
+//	Object{
+//	  name: String("foo"),
+//	  tags: StrictArray[
+//	    Number(3),
+//	  ],
+//	}
+//
+// Values containing themselves are rendered as <cycle> where they appear again, other containers appearing again,
+// like the ones read by References, as <same as path> with the path of their first appearance in the format of Walk.
+func (v *Value) String() string {
+	var buffer bytes.Buffer
+	v.writeString(&buffer, 0, "", newRendering())
+	return buffer.String()
+}
+
+func (v *Value) writeString(buffer *bytes.Buffer, indent int, path string, r *rendering) {
+	if v == nil {
+		buffer.WriteString("<nil>")
+		return
+	}
+	if r.isAncestor(v) {
+		buffer.WriteString("<cycle>")
+		return
+	}
+	name := v.Marker.String()
+	switch v.Marker {
+	case Null, Undefined, Unsupported:
+		buffer.WriteString(name)
+	case Number:
+		if number, ok := v.Value.(float64); ok {
+			fmt.Fprintf(buffer, "%s(%s)", name, strconv.FormatFloat(number, 'g', -1, 64))
+			return
+		}
+		fmt.Fprintf(buffer, "%s(%v)", name, v.Value)
+	case String, LongString, XmlDocument:
+		if str, ok := v.Value.(string); ok {
+			fmt.Fprintf(buffer, "%s(%q)", name, str)
+			return
+		}
+		fmt.Fprintf(buffer, "%s(%v)", name, v.Value)
+	case Date:
+		if t, ok := v.Value.(time.Time); ok {
+			fmt.Fprintf(buffer, "%s(%s)", name, t.Format(time.RFC3339Nano))
+			return
+		}
+		fmt.Fprintf(buffer, "%s(%v)", name, v.Value)
+	case Object, ECMAArray, TypedObject:
+		properties, ok := v.properties()
+		if !ok {
+			fmt.Fprintf(buffer, "%s(%v)", name, v.Value)
+			return
+		}
+		if first, ok := r.enter(v, path); ok {
+			fmt.Fprintf(buffer, "<same as %s>", first)
+			return
+		}
+		defer r.leave(v)
+		buffer.WriteString(name)
+		if v.Marker == TypedObject {
+			fmt.Fprintf(buffer, "(%q)", v.ClassName)
+		}
+		buffer.WriteByte('{')
+		for _, property := range properties {
+			writeIndent(buffer, indent+1)
+			if property == nil {
+				buffer.WriteString("<nil>,")
+				continue
+			}
+			buffer.WriteString(property.Name)
+			buffer.WriteString(": ")
+			property.writeString(buffer, indent+1, propertyPath(path, property.Name), r)
+			buffer.WriteByte(',')
+		}
+		if len(properties) > 0 {
+			writeIndent(buffer, indent)
+		}
+		buffer.WriteByte('}')
+	case StrictArray:
+		values, ok := v.Value.([]*Value)
+		if !ok && v.Value != nil {
+			fmt.Fprintf(buffer, "%s(%v)", name, v.Value)
+			return
+		}
+		if first, ok := r.enter(v, path); ok {
+			fmt.Fprintf(buffer, "<same as %s>", first)
+			return
+		}
+		defer r.leave(v)
+		buffer.WriteString(name)
+		buffer.WriteByte('[')
+		for i, arrayValue := range values {
+			writeIndent(buffer, indent+1)
+			arrayValue.writeString(buffer, indent+1, elementPath(path, i), r)
+			buffer.WriteByte(',')
+		}
+		if len(values) > 0 {
+			writeIndent(buffer, indent)
+		}
+		buffer.WriteByte(']')
+	case AvmPlusObject:
+		data, err := json.Marshal(v.Value)
+		if err != nil {
+			fmt.Fprintf(buffer, "%s(%v)", name, v.Value)
+			return
+		}
+		fmt.Fprintf(buffer, "%s(%s)", name, data)
+	default:
+		fmt.Fprintf(buffer, "%s(%v)", name, v.Value)
+	}
+}
+
+// writeIndent starts a new line with the indentation.
+func writeIndent(buffer *bytes.Buffer, indent int) {
+	buffer.WriteByte('\n')
+	buffer.WriteString(strings.Repeat("  ", indent))
+}
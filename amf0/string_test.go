@@ -0,0 +1,57 @@
+package amf0_test
+
+import (
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+func TestString(t *testing.T) {
+	data := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("name").String("foo").Name("tags").StrictArray(2, func(b *amf0test.Builder) {
+			b.Number(3).Reference(0)
+		}).Name("again").Reference(1)
+	}).Bytes()
+	value, _, err := amf0.Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	expected := `Object{
+  name: String("foo"),
+  tags: StrictArray[
+    Number(3),
+    <cycle>,
+  ],
+  again: <same as tags>,
+}`
+	if got := value.String(); got != expected {
+		t.Fatalf("got\n%s\nexpected\n%s", got, expected)
+	}
+}
+
+func TestStringSharedReferences(t *testing.T) {
+	value, _, err := amf0.Parse(sharedReferences(2))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	expected := `StrictArray[
+  StrictArray[
+    StrictArray[
+      Number(1),
+      Number(2),
+    ],
+    <same as [0][0]>,
+  ],
+  <same as [0]>,
+]`
+	if got := value.String(); got != expected {
+		t.Fatalf("got\n%s\nexpected\n%s", got, expected)
+	}
+	if value, _, err = amf0.Parse(sharedReferences(30)); err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := value.String(); len(got) > 8192 {
+		t.Fatalf("rendered %d bytes, expected the shared arrays once", len(got))
+	}
+}
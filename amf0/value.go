@@ -0,0 +1,375 @@
+package amf0
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/balazshorvath/goamf/amf3"
+)
+
+// NewNumber returns a Number.
+func NewNumber(number float64) *Value {
+	return &Value{Marker: Number, Value: number}
+}
+
+// NewString returns a String, or a LongString if it doesn't fit into a String.
+func NewString(str string) *Value {
+	if len(str) > math.MaxUint16 {
+		return &Value{Marker: LongString, Value: str}
+	}
+	return &Value{Marker: String, Value: str}
+}
+
+// NewBool returns a Boolean.
+func NewBool(b bool) *Value {
+	return &Value{Marker: Boolean, Value: b}
+}
+
+// NewObject returns an Object with the properties, which should have their Name set.
+func NewObject(properties ...*Value) *Value {
+	return &Value{Marker: Object, Value: append([]*Value{}, properties...)}
+}
+
+// NewECMAArray returns an ECMAArray with the properties of the map in the order of less,
+// or sorted by name if less is nil. The properties are shallow copies of the values with their names set to their keys,
+// the values are not modified. A nil value is a Null.
+func NewECMAArray(m map[string]*Value, less func(a, b string) bool) *Value {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	if less == nil {
+		sort.Strings(names)
+	} else {
+		sort.Slice(names, func(i, j int) bool {
+			return less(names[i], names[j])
+		})
+	}
+	properties := make([]*Value, 0, len(names))
+	for _, name := range names {
+		property := NewNull()
+		if m[name] != nil {
+			*property = *m[name]
+		}
+		property.Name = name
+		properties = append(properties, property)
+	}
+	return &Value{Marker: ECMAArray, Value: properties, Count: uint32(len(properties))}
+}
+
+// NewDate returns a Date. It's encoded with a zero time zone and the milliseconds since epoch as a double,
+// which keeps the time below milliseconds as a fraction, but rounds it to the precision of a double,
+// about a microsecond for the current dates.
+func NewDate(t time.Time) *Value {
+	return &Value{Marker: Date, Value: t}
+}
+
+// NewStrictArray returns a StrictArray with the items.
+func NewStrictArray(items ...*Value) *Value {
+	return &Value{Marker: StrictArray, Value: append([]*Value{}, items...)}
+}
+
+// NewNull returns a Null.
+func NewNull() *Value {
+	return &Value{Marker: Null}
+}
+
+// IsNull reports whether the value is a Null, a nil *Value is encoded as Null too.
+func (v *Value) IsNull() bool {
+	return v == nil || v.Marker == Null
+}
+
+// IsUndefined reports whether the value is an Undefined.
+func (v *Value) IsUndefined() bool {
+	return v != nil && v.Marker == Undefined
+}
+
+// StringValue returns the value of a String or LongString.
+func (v *Value) StringValue() (string, bool) {
+	if v == nil || (v.Marker != String && v.Marker != LongString) {
+		return "", false
+	}
+	str, ok := v.Value.(string)
+	return str, ok
+}
+
+// XMLDocument returns the value of an XmlDocument.
+func (v *Value) XMLDocument() (string, bool) {
+	if v == nil || v.Marker != XmlDocument {
+		return "", false
+	}
+	str, ok := v.Value.(string)
+	return str, ok
+}
+
+// Bytes returns the value of an AvmPlusObject holding an AMF3 ByteArray, AMF0 has no binary type of it's own.
+func (v *Value) Bytes() ([]byte, bool) {
+	if v == nil || v.Marker != AvmPlusObject {
+		return nil, false
+	}
+	amf3Value, ok := v.Value.(*amf3.Value)
+	if !ok {
+		return nil, false
+	}
+	return amf3Value.Bytes()
+}
+
+// Float64 returns the value of a Number.
+func (v *Value) Float64() (float64, bool) {
+	if v == nil || v.Marker != Number {
+		return 0, false
+	}
+	number, ok := v.Value.(float64)
+	return number, ok
+}
+
+// Int64 returns the value of a Number, if it's an integer in the range of int64.
+func (v *Value) Int64() (int64, bool) {
+	number, ok := v.Float64()
+	// MaxInt64 is rounded up to 2^63 as a float64
+	if !ok || number != math.Trunc(number) || number < math.MinInt64 || number >= math.MaxInt64 {
+		return 0, false
+	}
+	return int64(number), true
+}
+
+// Uint32 returns the value of a Number, if it's an integer in the range of uint32.
+func (v *Value) Uint32() (uint32, bool) {
+	number, ok := v.Float64()
+	if !ok || number != math.Trunc(number) || number < 0 || number > math.MaxUint32 {
+		return 0, false
+	}
+	return uint32(number), true
+}
+
+// Bool returns the value of a Boolean.
+func (v *Value) Bool() (bool, bool) {
+	if v == nil || v.Marker != Boolean {
+		return false, false
+	}
+	b, ok := v.Value.(bool)
+	return b, ok
+}
+
+// DateMillis returns the milliseconds since epoch of a Date. For parsed Dates it's the value as it was read,
+// which may have a precision or a NaN, that time.Time can't represent.
+func (v *Value) DateMillis() (float64, bool) {
+	if v == nil || v.Marker != Date {
+		return 0, false
+	}
+	if v.date != nil {
+		return v.date.millis, true
+	}
+	t, ok := v.Value.(time.Time)
+	if !ok {
+		return 0, false
+	}
+	return timeToMillis(t), true
+}
+
+// DateTimezone returns the signed minutes of the time zone of a Date. For parsed Dates it's the value as it was read,
+// the time.Time of them is in that zone, otherwise it's 0, like the encoder writes it.
+func (v *Value) DateTimezone() (int16, bool) {
+	if v == nil || v.Marker != Date {
+		return 0, false
+	}
+	if v.date != nil {
+		return v.date.timezone, true
+	}
+	_, ok := v.Value.(time.Time)
+	return 0, ok
+}
+
+// ToNative converts the value tree into plain Go types: Objects, ECMAArrays and TypedObjects become
+// map[string]interface{}, StrictArrays []interface{}, Numbers float64, Dates time.Time, strings string,
+// Null and Undefined nil. AvmPlusObjects are kept as *amf3.Value. A value not holding the type of it's marker
+// is an error, so is a value containing itself, plain Go types can't represent cycles.
+// Containers appearing more than once, like the ones read by References, are converted once
+// and their maps or slices are shared by the appearances.
+func (v *Value) ToNative() (interface{}, error) {
+	return newConversion(nil).toNative(v)
+}
+
+// Clone returns a deep copy of the value tree. Values appearing more than once in the tree
+// are copied once, so the copy shares them the same way, which keeps the references of the encoder.
+// The *amf3.Value of an AvmPlusObject is not copied.
+func (v *Value) Clone() *Value {
+	return v.clone(make(map[*Value]*Value))
+}
+
+func (v *Value) clone(clones map[*Value]*Value) *Value {
+	if v == nil {
+		return nil
+	}
+	if clone, ok := clones[v]; ok {
+		return clone
+	}
+	clone := &Value{}
+	*clone = *v
+	clones[v] = clone
+	if v.ref != nil {
+		clone.ref = v.ref.clone(clones)
+	}
+	if values, ok := v.Value.([]*Value); ok {
+		cloned := make([]*Value, len(values))
+		for i, value := range values {
+			cloned[i] = value.clone(clones)
+		}
+		clone.Value = cloned
+	}
+	return clone
+}
+
+// identity returns the key of the content of the value. The parser resolves a Reference to a new *Value
+// sharing the content of the container, so the content is the identity of the non-empty containers.
+func identity(value *Value) interface{} {
+	if values, ok := value.Value.([]*Value); ok && len(values) > 0 {
+		return contentIdentity{pointer: &values[0], length: len(values)}
+	}
+	return value
+}
+
+type contentIdentity struct {
+	pointer **Value
+	length  int
+}
+
+// Property returns the first property with the name of an Object, ECMAArray or TypedObject.
+func (v *Value) Property(name string) (*Value, bool) {
+	properties, ok := v.properties()
+	if !ok {
+		return nil, false
+	}
+	for _, property := range properties {
+		if property.Name == name {
+			return property, true
+		}
+	}
+	return nil, false
+}
+
+// AsMap returns the properties of an Object, ECMAArray or TypedObject by name.
+// The Marker of the value still tells them apart for encoding. Duplicate property names are an error.
+func (v *Value) AsMap() (map[string]*Value, error) {
+	properties, ok := v.properties()
+	if !ok {
+		return nil, fmt.Errorf("marker %v has no properties", v.marker())
+	}
+	result := make(map[string]*Value, len(properties))
+	for _, property := range properties {
+		if _, ok := result[property.Name]; ok {
+			return nil, fmt.Errorf("duplicate property %q", property.Name)
+		}
+		result[property.Name] = property
+	}
+	return result, nil
+}
+
+// SetProperty replaces the first property with the name of an Object, ECMAArray or TypedObject,
+// or appends it, if there's no such property. The name of the property is set to name,
+// a nil property is set as a Null. Other values are not modified.
+func (v *Value) SetProperty(name string, property *Value) {
+	properties, ok := v.properties()
+	if !ok {
+		return
+	}
+	if property == nil {
+		// The property needs a name
+		property = NewNull()
+	}
+	property.Name = name
+	for i, p := range properties {
+		if p.Name == name {
+			properties[i] = property
+			return
+		}
+	}
+	v.Value = append(properties, property)
+}
+
+// MergeObjects returns a copy of the base Object or ECMAArray with the properties of the overlay Object or ECMAArray.
+// The properties of the overlay replace the first property of the base with the same name, or are appended.
+// If both properties are Objects or ECMAArrays, they are merged the same way instead. The inputs are not modified.
+func MergeObjects(base *Value, overlay *Value) (*Value, error) {
+	if !isObject(base) || !isObject(overlay) {
+		return nil, fmt.Errorf("can not merge %v into %v, expected Objects or ECMAArrays", overlay.marker(), base.marker())
+	}
+	merged := base.Clone()
+	merged.mergeObject(overlay, make(map[*Value]bool))
+	return merged, nil
+}
+
+// mergeObject merges the overlay into the copy of the base, the overlays being merged are not merged again.
+func (v *Value) mergeObject(overlay *Value, merging map[*Value]bool) {
+	merging[overlay] = true
+	defer delete(merging, overlay)
+	properties, _ := overlay.properties()
+	for _, property := range properties {
+		if property == nil {
+			continue
+		}
+		existing, ok := v.Property(property.Name)
+		if ok && isObject(existing) && isObject(property) && !merging[property] {
+			existing.mergeObject(property, merging)
+			continue
+		}
+		v.SetProperty(property.Name, property.Clone())
+	}
+	if v.Marker == ECMAArray {
+		properties, _ := v.properties()
+		v.Count = uint32(len(properties))
+	}
+}
+
+func isObject(v *Value) bool {
+	if v == nil || (v.Marker != Object && v.Marker != ECMAArray) {
+		return false
+	}
+	_, ok := v.properties()
+	return ok
+}
+
+// DeleteProperty removes the properties with the name of an Object, ECMAArray or TypedObject, keeping the order.
+// Other values are not modified.
+func (v *Value) DeleteProperty(name string) {
+	properties, ok := v.properties()
+	if !ok {
+		return
+	}
+	kept := properties[:0]
+	for _, p := range properties {
+		if p.Name != name {
+			kept = append(kept, p)
+		}
+	}
+	for i := len(kept); i < len(properties); i++ {
+		properties[i] = nil
+	}
+	v.Value = kept
+}
+
+// properties returns the properties of an Object, ECMAArray or TypedObject.
+func (v *Value) properties() ([]*Value, bool) {
+	if v == nil {
+		return nil, false
+	}
+	switch v.Marker {
+	case Object, ECMAArray, TypedObject:
+		if v.Value == nil {
+			return nil, true
+		}
+		properties, ok := v.Value.([]*Value)
+		return properties, ok
+	}
+	return nil, false
+}
+
+// marker returns the marker, Null for nil values.
+func (v *Value) marker() Marker {
+	if v == nil {
+		return Null
+	}
+	return v.Marker
+}
@@ -0,0 +1,131 @@
+package amf0_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+func TestToNative(t *testing.T) {
+	data := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("name").String("foo").Name("tags").StrictArray(2, func(b *amf0test.Builder) {
+			b.Number(3).Undefined()
+		}).Name("meta").ECMAArray(1, func(b *amf0test.Builder) {
+			b.Name("ok").Boolean(true)
+		})
+	}).Bytes()
+	value, _, err := amf0.Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	native, err := value.ToNative()
+	if err != nil {
+		t.Fatalf("ToNative failed: %v", err)
+	}
+	expected := map[string]interface{}{
+		"name": "foo",
+		"tags": []interface{}{3.0, nil},
+		"meta": map[string]interface{}{"ok": true},
+	}
+	if !reflect.DeepEqual(native, expected) {
+		t.Fatalf("got %#v, expected %#v", native, expected)
+	}
+}
+
+func TestToNativeErrors(t *testing.T) {
+	cycle, _, err := amf0.Parse(amf0test.New().StrictArray(1, func(b *amf0test.Builder) {
+		b.Reference(0)
+	}).Bytes())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	tests := []struct {
+		name  string
+		value *amf0.Value
+	}{
+		{"cycle", cycle},
+		{"nested invalid value", amf0.NewObject(&amf0.Value{Name: "n", Marker: amf0.Number, Value: "1"})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if native, err := test.value.ToNative(); err == nil {
+				t.Fatalf("got %#v, expected an error", native)
+			}
+		})
+	}
+}
+
+func TestDateTimezone(t *testing.T) {
+	for _, timezone := range []int16{120, -300, 0} {
+		value, _, err := amf0.Parse(amf0test.New().Date(1577836800000, timezone).Bytes())
+		if err != nil {
+			t.Fatalf("Parse of the time zone %d failed: %v", timezone, err)
+		}
+		got, ok := value.DateTimezone()
+		if !ok || got != timezone {
+			t.Errorf("got the time zone %d, expected %d", got, timezone)
+		}
+		date := value.Value.(time.Time)
+		if _, offset := date.Zone(); offset != int(timezone)*60 {
+			t.Errorf("got the offset %d, expected %d minutes", offset, timezone)
+		}
+		if !date.Equal(time.Unix(1577836800, 0)) {
+			t.Errorf("the time zone %d changed the time to %v", timezone, date)
+		}
+	}
+}
+
+func TestToNativeSharedReferences(t *testing.T) {
+	value, _, err := amf0.Parse(sharedReferences(30))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	native, err := value.ToNative()
+	if err != nil {
+		t.Fatalf("ToNative failed: %v", err)
+	}
+	// The appearances of an array share the converted slice
+	elements := native.([]interface{})
+	first, second := elements[0].([]interface{}), elements[1].([]interface{})
+	if &first[0] != &second[0] {
+		t.Fatal("the Reference was converted again")
+	}
+}
+
+func TestSetProperty(t *testing.T) {
+	value := amf0.NewObject()
+	value.SetProperty("a", amf0.NewNumber(1))
+	value.SetProperty("b", nil)
+	value.SetProperty("a", amf0.NewNumber(2))
+	expected := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("a").Number(2).Name("b").Null()
+	}).Bytes()
+	parsed, _, err := amf0.Parse(expected)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !value.Equal(parsed) {
+		t.Fatalf("got %v, expected %v", value, parsed)
+	}
+}
+
+func TestNewECMAArray(t *testing.T) {
+	shared := amf0.NewNumber(1)
+	value := amf0.NewECMAArray(map[string]*amf0.Value{"b": shared, "a": shared, "c": nil}, nil)
+	expected := amf0test.New().ECMAArray(3, func(b *amf0test.Builder) {
+		b.Name("a").Number(1).Name("b").Number(1).Name("c").Null()
+	}).Bytes()
+	parsed, _, err := amf0.Parse(expected)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !value.Equal(parsed) {
+		t.Fatalf("got %v, expected %v", value, parsed)
+	}
+	if shared.Name != "" {
+		t.Fatalf("the name of the value was set to %q", shared.Name)
+	}
+}
@@ -0,0 +1,210 @@
+package amf0
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Walk calls fn for the value and every value in it's tree, depth-first, in order.
+// The path of the root is empty, properties are separated by dots, array elements have their index in brackets,
+// like "server.clients[2].name". Returning an error from fn stops the walk, Walk returns that error.
+// Containers appearing more than once, like the ones read by References or containing themselves,
+// are passed to fn every time, but descended into the first time only.
+func (v *Value) Walk(fn func(path string, v *Value) error) error {
+	return v.walk("", fn, make(map[interface{}]bool))
+}
+
+// walk walks the tree, visited has the identities of the containers descended into.
+func (v *Value) walk(path string, fn func(path string, v *Value) error, visited map[interface{}]bool) error {
+	if err := fn(path, v); err != nil {
+		return err
+	}
+	if v == nil {
+		return nil
+	}
+	key := identity(v)
+	if visited[key] {
+		return nil
+	}
+	visited[key] = true
+	if properties, ok := v.properties(); ok {
+		for _, property := range properties {
+			if err := property.walk(propertyPath(path, property.Name), fn, visited); err != nil {
+				return err
+			}
+		}
+	} else if values, ok := v.Value.([]*Value); ok && v.Marker == StrictArray {
+		for i, arrayValue := range values {
+			if err := arrayValue.walk(elementPath(path, i), fn, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Collect returns the values of the tree with the marker, in the order of Walk. The values in containers
+// appearing more than once are collected once, the containers themselves every time.
+func (v *Value) Collect(marker Marker) []*Value {
+	var values []*Value
+	_ = v.Walk(func(path string, value *Value) error {
+		if value != nil && value.Marker == marker {
+			values = append(values, value)
+		}
+		return nil
+	})
+	return values
+}
+
+// SetPath replaces the value at the path of the tree, which has the format of the paths of Walk,
+// like "server.clients[2].name". Properties are replaced like with SetProperty, array elements keep their index.
+// With create, the missing properties are added and the missing values on the way are created as Objects,
+// otherwise they are an error. Missing arrays are not created, an index after a missing value is an error.
+// The path is checked before the tree is modified, the tree is not modified if there's an error.
+// Property names with dots or brackets can't be in a path.
+func (v *Value) SetPath(path string, newValue *Value, create bool) error {
+	segments, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	if err := v.setPath(path, segments, newValue, create, false); err != nil {
+		return err
+	}
+	return v.setPath(path, segments, newValue, create, true)
+}
+
+// setPath follows the path, the tree is only modified if apply is set.
+func (v *Value) setPath(path string, segments pathSegments, newValue *Value, create bool, apply bool) error {
+	current := v
+	for i, segment := range segments {
+		var next *Value
+		created := false
+		if segment.element {
+			values, ok := current.Value.([]*Value)
+			if current.marker() != StrictArray || (!ok && current.Value != nil) {
+				return fmt.Errorf("path %q: %v at %q has no elements", path, current.marker(), segments.prefix(i))
+			}
+			if segment.index >= len(values) {
+				return fmt.Errorf("path %q: element %d is out of the %d elements at %q", path, segment.index, len(values), segments.prefix(i))
+			}
+			if i == len(segments)-1 {
+				if apply {
+					values[segment.index] = newValue
+				}
+				return nil
+			}
+			next = values[segment.index]
+			if next == nil && create {
+				next = NewObject()
+				created = true
+				if apply {
+					values[segment.index] = next
+				}
+			}
+		} else {
+			if _, ok := current.properties(); !ok {
+				return fmt.Errorf("path %q: %v at %q has no properties", path, current.marker(), segments.prefix(i))
+			}
+			property, ok := current.Property(segment.name)
+			if i == len(segments)-1 {
+				if !ok && !create {
+					return fmt.Errorf("path %q: missing property %q", path, segments.prefix(i+1))
+				}
+				if apply {
+					current.SetProperty(segment.name, newValue)
+				}
+				return nil
+			}
+			next = property
+			if !ok && create {
+				next = NewObject()
+				created = true
+				if apply {
+					current.SetProperty(segment.name, next)
+				}
+			}
+		}
+		if next == nil {
+			return fmt.Errorf("path %q: missing value %q", path, segments.prefix(i+1))
+		}
+		if created && segments[i+1].element {
+			return fmt.Errorf("path %q: missing value %q has an index, only Objects are created", path, segments.prefix(i+1))
+		}
+		current = next
+	}
+	return nil
+}
+
+// pathSegment is a property name or an array index of a path.
+type pathSegment struct {
+	name    string
+	index   int
+	element bool
+}
+
+type pathSegments []pathSegment
+
+// parsePath splits the path into the property names and the array indexes.
+func parsePath(path string) (pathSegments, error) {
+	if path == "" {
+		return nil, errors.New("empty path")
+	}
+	var segments pathSegments
+	for i := 0; i < len(path); {
+		if path[i] == '[' {
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %q: unterminated index at %d", path, i)
+			}
+			index, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("path %q: invalid index %q", path, path[i+1:i+end])
+			}
+			segments = append(segments, pathSegment{index: index, element: true})
+			i += end + 1
+			continue
+		}
+		if len(segments) > 0 {
+			if path[i] != '.' {
+				return nil, fmt.Errorf("path %q: expected '.' or '[' at %d", path, i)
+			}
+			i++
+		}
+		end := strings.IndexAny(path[i:], ".[")
+		if end < 0 {
+			end = len(path) - i
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("path %q: empty property name at %d", path, i)
+		}
+		segments = append(segments, pathSegment{name: path[i : i+end]})
+		i += end
+	}
+	return segments, nil
+}
+
+// prefix returns the path of the first n segments.
+func (segments pathSegments) prefix(n int) string {
+	path := ""
+	for _, segment := range segments[:n] {
+		if segment.element {
+			path = elementPath(path, segment.index)
+		} else {
+			path = propertyPath(path, segment.name)
+		}
+	}
+	return path
+}
+
+func propertyPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func elementPath(path string, index int) string {
+	return path + "[" + strconv.Itoa(index) + "]"
+}
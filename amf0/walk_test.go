@@ -0,0 +1,146 @@
+package amf0_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf0"
+	"github.com/balazshorvath/goamf/amf0/amf0test"
+)
+
+func TestWalk(t *testing.T) {
+	data := amf0test.New().Object(func(b *amf0test.Builder) {
+		b.Name("server").Object(func(b *amf0test.Builder) {
+			b.Name("clients").StrictArray(2, func(b *amf0test.Builder) {
+				b.Null().Object(func(b *amf0test.Builder) {
+					b.Name("name").String("foo")
+				})
+			})
+		}).Name("self").Reference(0)
+	}).Bytes()
+	value, _, err := amf0.Parse(data)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	var paths []string
+	if err := value.Walk(func(path string, v *amf0.Value) error {
+		paths = append(paths, path)
+		return nil
+	}); err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	expected := []string{"", "server", "server.clients", "server.clients[0]", "server.clients[1]", "server.clients[1].name", "self"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Fatalf("walked %q, expected %q", paths, expected)
+	}
+	stop := errors.New("stop")
+	calls := 0
+	err = value.Walk(func(path string, v *amf0.Value) error {
+		calls++
+		if path == "server.clients" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || calls != 3 {
+		t.Fatalf("got error %v after %d calls, expected the error of fn after 3", err, calls)
+	}
+}
+
+func TestWalkSharedReferences(t *testing.T) {
+	value, _, err := amf0.Parse(sharedReferences(30))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	calls := 0
+	_ = value.Walk(func(path string, v *amf0.Value) error {
+		calls++
+		return nil
+	})
+	// The root, then the two elements of the arrays descended into
+	if expected := 1 + 2*31; calls != expected {
+		t.Fatalf("fn was called %d times, expected %d", calls, expected)
+	}
+}
+
+func TestCollectSharedReferences(t *testing.T) {
+	value, _, err := amf0.Parse(sharedReferences(30))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	// The innermost array is descended into once
+	if numbers := value.Collect(amf0.Number); len(numbers) != 2 {
+		t.Fatalf("collected %d Numbers, expected 2", len(numbers))
+	}
+	// Every array but the root appears twice
+	if arrays := value.Collect(amf0.StrictArray); len(arrays) != 1+2*30 {
+		t.Fatalf("collected %d StrictArrays, expected %d", len(arrays), 1+2*30)
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	tree := func() *amf0.Value {
+		value, _, err := amf0.Parse(amf0test.New().Object(func(b *amf0test.Builder) {
+			b.Name("server").Object(func(b *amf0test.Builder) {
+				b.Name("clients").StrictArray(2, func(b *amf0test.Builder) {
+					b.Null().Object(func(b *amf0test.Builder) {
+						b.Name("name").String("foo")
+					})
+				})
+			})
+		}).Bytes())
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		return value
+	}
+	tests := []struct {
+		name   string
+		path   string
+		create bool
+		// The path of the new value after SetPath, empty if it fails
+		expected string
+	}{
+		{"replace a property", "server.clients[1].name", false, "server.clients[1].name"},
+		{"replace an element", "server.clients[0]", false, "server.clients[0]"},
+		{"missing property", "server.port", false, ""},
+		{"create a property", "server.port", true, "server.port"},
+		{"create Objects", "a.b.c", true, "a.b.c"},
+		{"property of a Null element", "server.clients[0].name", true, ""},
+		{"index after a created Object", "a.b[0].c", true, ""},
+		{"index out of the elements", "server.clients[2]", true, ""},
+		{"index of an Object", "server[0]", true, ""},
+		{"property of an array", "server.clients.name", true, ""},
+		{"invalid path", "server..name", true, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value := tree()
+			newValue := amf0.NewString("bar")
+			err := value.SetPath(test.path, newValue, test.create)
+			if test.expected == "" {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if !value.Equal(tree()) {
+					t.Fatalf("the failing SetPath modified the tree: %v", value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetPath failed: %v", err)
+			}
+			var found *amf0.Value
+			_ = value.Walk(func(path string, v *amf0.Value) error {
+				if path == test.expected {
+					found = v
+				}
+				return nil
+			})
+			if found != newValue {
+				t.Fatalf("got %v at %q, expected the new value in %v", found, test.expected, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,471 @@
+package amf3
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Spec @ https://www.adobe.com/content/dam/acom/en/devnet/pdf/amf-file-format-spec.pdf
+type Marker byte
+
+const (
+	Undefined    Marker = 0x00
+	Null                = 0x01
+	False               = 0x02
+	True                = 0x03
+	Integer             = 0x04 // U29, variable length, signed 29 bit integer
+	Double              = 0x05 // 8 bytes IEEE-754 double - network/big endian
+	String              = 0x06 // U29S-ref, UTF-8, references the string table
+	XmlDocument         = 0x07 // U29X-ref, UTF-8, references the object table
+	Date                = 0x08 // U29D-ref, followed by 8 bytes of double timestamp of millis
+	Array               = 0x09 // U29A-ref, associative part and dense part
+	Object              = 0x0A // U29O-ref, traits and the members
+	Xml                 = 0x0B // U29X-ref, UTF-8, references the object table
+	ByteArray           = 0x0C // U29B-ref, raw bytes
+	VectorInt           = 0x0D // Not supported
+	VectorUint          = 0x0E // Not supported
+	VectorDouble        = 0x0F // Not supported
+	VectorObject        = 0x10 // Not supported
+	Dictionary          = 0x11 // Not supported
+)
+
+// Value represents an AMF3 value with a type, a value and optionally a name.
+// The name is the property name of the values in Objects and the associative part of Arrays.
+// An Object has it's class name in ClassName, which is empty for anonymous objects.
+// Objects have named properties, Arrays are stored as *ArrayValue.
+// Reference types are already resolved, there are no such types to be found in this tree.
+type Value struct {
+	Marker    Marker
+	Name      string
+	ClassName string
+	Value     interface{}
+}
+
+// ArrayValue holds the associative (named) and the dense (ordinal) part of an AMF3 array.
+type ArrayValue struct {
+	Associative []*Value
+	Dense       []*Value
+}
+
+// traits describe the class of an object, they are referenced by the subsequent objects of the same class.
+type traits struct {
+	className   string
+	dynamic     bool
+	memberNames []string
+}
+
+const (
+	DefaultMaxDepth = 512
+	DefaultMaxBytes = 64 << 20
+)
+
+// maxPreallocation is the largest buffer allocated before reading the data.
+const maxPreallocation = 64 << 10
+
+var (
+	ErrMaxDepthExceeded = errors.New("maximum depth exceeded")
+	ErrMaxBytesExceeded = errors.New("maximum bytes exceeded")
+)
+
+// Parser reads AMF3 values from the reader.
+// MaxDepth limits the nesting of objects and arrays, MaxBytes limits the amount of bytes read by a single Parse,
+// values less than 1 disable the limit.
+type Parser struct {
+	MaxDepth int
+	MaxBytes int
+
+	reader    io.Reader
+	strings   []string
+	objects   []*Value
+	traits    []*traits
+	bytesRead int
+	// Offset of the value being parsed, an EOF is only expected at the start
+	valueStart int
+	depth      int
+	// The objects being parsed and the references read to them, which are completed at the end of the object
+	open    []*Value
+	pending []pendingReference
+}
+
+// pendingReference is a value read by a reference to an object, that was not complete yet.
+type pendingReference struct {
+	value *Value
+	ref   *Value
+}
+
+func New(reader io.Reader) *Parser {
+	return &Parser{
+		MaxDepth: DefaultMaxDepth,
+		MaxBytes: DefaultMaxBytes,
+		reader:   reader,
+	}
+}
+
+// Reset clears the reference tables and the state of the parser and continues with the reader.
+func (p *Parser) Reset(reader io.Reader) {
+	p.reader = reader
+	p.strings = p.strings[:0]
+	for i := range p.objects {
+		p.objects[i] = nil
+	}
+	p.objects = p.objects[:0]
+	for i := range p.traits {
+		p.traits[i] = nil
+	}
+	p.traits = p.traits[:0]
+	p.bytesRead = 0
+	p.valueStart = 0
+	p.depth = 0
+}
+
+// Parse reads the next value. The reference tables are kept between the calls.
+// The returned bytesRead is the total amount of bytes read by the parser.
+// If the reader had no more values, the error is io.EOF, a value ending early results in io.ErrUnexpectedEOF.
+func (p *Parser) Parse() (*Value, int, error) {
+	p.valueStart = p.bytesRead
+	p.open = p.open[:0]
+	p.pending = p.pending[:0]
+	value, err := p.parseNamed("")
+	if err != nil {
+		return nil, p.bytesRead, fmt.Errorf("amf3 offset %d: %w", p.bytesRead, err)
+	}
+	return value, p.bytesRead, nil
+}
+
+func (p *Parser) parseValue(value *Value) error {
+	p.depth++
+	defer func() {
+		p.depth--
+	}()
+	if p.MaxDepth > 0 && p.depth > p.MaxDepth {
+		return fmt.Errorf("%w: %d", ErrMaxDepthExceeded, p.MaxDepth)
+	}
+	switch value.Marker {
+	case Undefined, Null:
+		value.Value = nil
+	case False:
+		value.Value = false
+	case True:
+		value.Value = true
+	case Integer:
+		u, err := p.readU29()
+		if err != nil {
+			return err
+		}
+		// Sign extend the 29 bit integer
+		if u&0x10000000 != 0 {
+			value.Value = int32(u) - 0x20000000
+		} else {
+			value.Value = int32(u)
+		}
+	case Double:
+		number, err := p.readDouble()
+		if err != nil {
+			return err
+		}
+		value.Value = number
+	case String:
+		str, err := p.readString()
+		if err != nil {
+			return err
+		}
+		value.Value = str
+	case XmlDocument, Xml:
+		u, isReference, err := p.readReference(value)
+		if err != nil || isReference {
+			return err
+		}
+		data, err := p.readBytes(int(u >> 1))
+		if err != nil {
+			return err
+		}
+		value.Value = string(data)
+		p.objects = append(p.objects, value)
+	case Date:
+		_, isReference, err := p.readReference(value)
+		if err != nil || isReference {
+			return err
+		}
+		millis, err := p.readDouble()
+		if err != nil {
+			return err
+		}
+		value.Value = millisToTime(millis)
+		p.objects = append(p.objects, value)
+	case Array:
+		u, isReference, err := p.readReference(value)
+		if err != nil || isReference {
+			return err
+		}
+		array := &ArrayValue{}
+		value.Value = array
+		p.objects = append(p.objects, value)
+		// Associative part, terminated by an empty string
+		for {
+			name, err := p.readString()
+			if err != nil {
+				return err
+			}
+			if name == "" {
+				break
+			}
+			element, err := p.parseNamed(name)
+			if err != nil {
+				return err
+			}
+			array.Associative = append(array.Associative, element)
+		}
+		// Dense part
+		length := int(u >> 1)
+		for i := 0; i < length; i++ {
+			element, err := p.parseNamed("")
+			if err != nil {
+				return err
+			}
+			array.Dense = append(array.Dense, element)
+		}
+	case Object:
+		u, isReference, err := p.readReference(value)
+		if err != nil || isReference {
+			return err
+		}
+		t, err := p.readTraits(u)
+		if err != nil {
+			return err
+		}
+		value.ClassName = t.className
+		p.objects = append(p.objects, value)
+		p.open = append(p.open, value)
+		var properties []*Value
+		// Sealed members
+		for _, name := range t.memberNames {
+			property, err := p.parseNamed(name)
+			if err != nil {
+				return err
+			}
+			properties = append(properties, property)
+		}
+		// Dynamic members, terminated by an empty string
+		if t.dynamic {
+			for {
+				name, err := p.readString()
+				if err != nil {
+					return err
+				}
+				if name == "" {
+					break
+				}
+				property, err := p.parseNamed(name)
+				if err != nil {
+					return err
+				}
+				properties = append(properties, property)
+			}
+		}
+		value.Value = properties
+		p.closeObject(value)
+	case ByteArray:
+		u, isReference, err := p.readReference(value)
+		if err != nil || isReference {
+			return err
+		}
+		data, err := p.readBytes(int(u >> 1))
+		if err != nil {
+			return err
+		}
+		value.Value = data
+		p.objects = append(p.objects, value)
+	case VectorInt, VectorUint, VectorDouble, VectorObject, Dictionary:
+		return fmt.Errorf("unsupported type %d", value.Marker)
+	default:
+		return fmt.Errorf("unknown type %d", value.Marker)
+	}
+	return nil
+}
+
+// parseNamed reads a marker and the value following it.
+func (p *Parser) parseNamed(name string) (*Value, error) {
+	data, err := p.readBytes(1)
+	if err != nil {
+		return nil, err
+	}
+	value := &Value{
+		Marker: Marker(data[0]),
+		Name:   name,
+	}
+	if err := p.parseValue(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// readReference reads the U29 header of an object table entry.
+// If the low bit is not set, the rest is an index to the object table, the value gets resolved.
+func (p *Parser) readReference(value *Value) (uint32, bool, error) {
+	u, err := p.readU29()
+	if err != nil {
+		return 0, false, err
+	}
+	if u&1 != 0 {
+		return u, false, nil
+	}
+	index := int(u >> 1)
+	if index > len(p.objects)-1 {
+		return 0, false, errors.New("reference index is greater, than the amount of available reference objects")
+	}
+	ref := p.objects[index]
+	value.Value = ref.Value
+	value.Marker = ref.Marker
+	value.ClassName = ref.ClassName
+	// The members of an object are set at it's end
+	for _, open := range p.open {
+		if open == ref {
+			p.pending = append(p.pending, pendingReference{value: value, ref: ref})
+			break
+		}
+	}
+	return u, true, nil
+}
+
+// closeObject completes the values read by a reference to the object, while it was parsed.
+func (p *Parser) closeObject(object *Value) {
+	for i := len(p.open) - 1; i >= 0; i-- {
+		if p.open[i] == object {
+			p.open = p.open[:i]
+			break
+		}
+	}
+	kept := p.pending[:0]
+	for _, pending := range p.pending {
+		if pending.ref == object {
+			pending.value.Value = object.Value
+		} else {
+			kept = append(kept, pending)
+		}
+	}
+	p.pending = kept
+}
+
+func (p *Parser) readTraits(u uint32) (*traits, error) {
+	// Traits reference
+	if u&2 == 0 {
+		index := int(u >> 2)
+		if index > len(p.traits)-1 {
+			return nil, errors.New("traits reference index is greater, than the amount of available traits")
+		}
+		return p.traits[index], nil
+	}
+	className, err := p.readString()
+	if err != nil {
+		return nil, err
+	}
+	// Externalizable, the format is defined by the class itself
+	if u&4 != 0 {
+		return nil, fmt.Errorf("externalizable object %q is not supported", className)
+	}
+	t := &traits{
+		className: className,
+		dynamic:   u&8 != 0,
+	}
+	count := int(u >> 4)
+	for i := 0; i < count; i++ {
+		name, err := p.readString()
+		if err != nil {
+			return nil, err
+		}
+		t.memberNames = append(t.memberNames, name)
+	}
+	p.traits = append(p.traits, t)
+	return t, nil
+}
+
+// readU29 reads the variable length unsigned 29 bit integer.
+// The first 3 bytes carry 7 bits each, the most significant bit marks if there's another byte.
+// The 4th byte is used fully.
+func (p *Parser) readU29() (uint32, error) {
+	var result uint32
+	for i := 0; i < 4; i++ {
+		data, err := p.readBytes(1)
+		if err != nil {
+			return 0, err
+		}
+		if i == 3 {
+			return result<<8 | uint32(data[0]), nil
+		}
+		result = result<<7 | uint32(data[0]&0x7F)
+		if data[0]&0x80 == 0 {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (p *Parser) readString() (string, error) {
+	u, err := p.readU29()
+	if err != nil {
+		return "", err
+	}
+	if u&1 == 0 {
+		index := int(u >> 1)
+		if index > len(p.strings)-1 {
+			return "", errors.New("string reference index is greater, than the amount of available strings")
+		}
+		return p.strings[index], nil
+	}
+	data, err := p.readBytes(int(u >> 1))
+	if err != nil {
+		return "", err
+	}
+	str := string(data)
+	// Empty strings are never sent by reference
+	if str != "" {
+		p.strings = append(p.strings, str)
+	}
+	return str, nil
+}
+
+func (p *Parser) readDouble() (float64, error) {
+	data, err := p.readBytes(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+}
+
+func (p *Parser) readBytes(length int) ([]byte, error) {
+	if p.MaxBytes > 0 && length > p.MaxBytes-(p.bytesRead-p.valueStart) {
+		return nil, fmt.Errorf("%w: reading %d bytes, limit is %d", ErrMaxBytesExceeded, length, p.MaxBytes)
+	}
+	var buffer []byte
+	var n int
+	var err error
+	if length > maxPreallocation {
+		// The length comes from the input, so the buffer grows with the data actually read
+		var b bytes.Buffer
+		var copied int64
+		copied, err = io.CopyN(&b, p.reader, int64(length))
+		buffer, n = b.Bytes(), int(copied)
+	} else {
+		buffer = make([]byte, length)
+		n, err = io.ReadFull(p.reader, buffer)
+	}
+	p.bytesRead += n
+	if err == io.EOF && p.bytesRead > p.valueStart {
+		return nil, io.ErrUnexpectedEOF
+	} else if err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// millisToTime converts the milliseconds since epoch to a UTC time.Time.
+func millisToTime(millis float64) time.Time {
+	sec := math.Floor(millis / 1000)
+	nsec := (millis - sec*1000) * float64(time.Millisecond)
+	return time.Unix(int64(sec), int64(nsec)).UTC()
+}
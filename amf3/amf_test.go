@@ -0,0 +1,227 @@
+package amf3_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf3"
+)
+
+func TestParseSelfReference(t *testing.T) {
+	// A dynamic anonymous object with the member self referencing it
+	data := []byte{amf3.Object, 0x0B, 0x01, 0x05, 'i', 'd', amf3.Integer, 0x01, 0x09, 's', 'e', 'l', 'f', amf3.Object, 0x00, 0x01}
+	value, _, err := amf3.New(bytes.NewReader(data)).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	properties := value.Value.([]*amf3.Value)
+	self := properties[1]
+	members, ok := self.Value.([]*amf3.Value)
+	if !ok || len(members) != 2 || members[0].Value != int32(1) {
+		t.Fatalf("the reference to the object being parsed has no members: %#v", self.Value)
+	}
+	var buffer bytes.Buffer
+	if err := amf3.NewEncoder(&buffer).Encode(value); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if !bytes.Equal(buffer.Bytes(), data) {
+		t.Fatalf("encoded % x, expected % x", buffer.Bytes(), data)
+	}
+}
+
+func TestMarshalJSONCycle(t *testing.T) {
+	// An array containing itself
+	data := []byte{amf3.Array, 0x03, 0x01, amf3.Array, 0x00}
+	value, _, err := amf3.New(bytes.NewReader(data)).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if _, err := value.MarshalJSON(); err == nil {
+		t.Fatal("a value containing itself was rendered")
+	}
+}
+
+// sharedReferences returns nested arrays of the depth, every array holds the next one and a reference to it.
+func sharedReferences(depth int) []byte {
+	var data []byte
+	var nest func(level int)
+	nest = func(level int) {
+		// Two dense elements without an associative part
+		data = append(data, amf3.Array, 0x05, 0x01)
+		if level == depth {
+			data = append(data, amf3.Integer, 0x01, amf3.Integer, 0x02)
+			return
+		}
+		// The arrays are in the object table in the order of their levels
+		nest(level + 1)
+		data = append(data, amf3.Array, byte(level+1)<<1)
+	}
+	nest(0)
+	return data
+}
+
+func TestMarshalJSONSharedReferences(t *testing.T) {
+	value, _, err := amf3.New(bytes.NewReader(sharedReferences(2))).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	encoded, err := value.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	if expected := `[[[1,2],{"$ref":"[0][0]"}],{"$ref":"[0]"}]`; string(encoded) != expected {
+		t.Fatalf("got %s, expected %s", encoded, expected)
+	}
+	value, _, err = amf3.New(bytes.NewReader(sharedReferences(30))).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if encoded, err = value.MarshalJSON(); err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+	if len(encoded) > 4096 {
+		t.Fatalf("rendered %d bytes, expected the shared arrays once", len(encoded))
+	}
+}
+
+func TestParseInteger(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected int32
+	}{
+		{"zero", []byte{0x00}, 0},
+		{"1 byte max", []byte{0x7F}, 127},
+		{"2 bytes min", []byte{0x81, 0x00}, 128},
+		{"2 bytes max", []byte{0xFF, 0x7F}, 16383},
+		{"3 bytes min", []byte{0x81, 0x80, 0x00}, 16384},
+		{"3 bytes max", []byte{0xFF, 0xFF, 0x7F}, 2097151},
+		{"4 bytes min", []byte{0x80, 0xC0, 0x80, 0x00}, 2097152},
+		// The 4th byte is used fully, the 29th bit is the sign
+		{"max", []byte{0xBF, 0xFF, 0xFF, 0xFF}, 1<<28 - 1},
+		{"min", []byte{0xC0, 0x80, 0x80, 0x00}, -1 << 28},
+		{"minus one", []byte{0xFF, 0xFF, 0xFF, 0xFF}, -1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := append([]byte{amf3.Integer}, test.data...)
+			value, bytesRead, err := amf3.New(bytes.NewReader(data)).Parse()
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if bytesRead != len(data) {
+				t.Fatalf("read %d bytes, expected %d", bytesRead, len(data))
+			}
+			if value.Value != test.expected {
+				t.Fatalf("got %v, expected %d", value.Value, test.expected)
+			}
+			var buffer bytes.Buffer
+			if err := amf3.NewEncoder(&buffer).Encode(value); err != nil {
+				t.Fatalf("Encode failed: %v", err)
+			}
+			if !bytes.Equal(buffer.Bytes(), data) {
+				t.Fatalf("encoded % x, expected % x", buffer.Bytes(), data)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"string reference", []byte{amf3.Array, 0x05, 0x01, amf3.String, 0x07, 'f', 'o', 'o', amf3.String, 0x00}, `["foo","foo"]`},
+		// The empty string is not in the table, the first reference is the first non-empty string
+		{"empty string not referenced", []byte{amf3.Array, 0x07, 0x01, amf3.String, 0x01, amf3.String, 0x03, 'a', amf3.String, 0x00}, `["","a","a"]`},
+		{"property name reference", []byte{amf3.Array, 0x05, 0x01, amf3.String, 0x03, 'a', amf3.Object, 0x0B, 0x01, 0x00, amf3.Integer, 0x01, 0x01}, `["a",{"a":1}]`},
+		{"object reference", []byte{amf3.Array, 0x05, 0x01, amf3.Object, 0x0B, 0x01, 0x03, 'a', amf3.Integer, 0x01, 0x01, amf3.Object, 0x02}, `[{"a":1},{"$ref":"[0]"}]`},
+		{"sealed traits", []byte{amf3.Object, 0x23, 0x07, 'F', 'o', 'o', 0x03, 'a', 0x03, 'b', amf3.Integer, 0x01, amf3.Integer, 0x02}, `{"a":1,"b":2}`},
+		{"dynamic traits", []byte{amf3.Object, 0x1B, 0x01, 0x03, 'a', amf3.Integer, 0x01, 0x03, 'b', amf3.Integer, 0x02, 0x01}, `{"a":1,"b":2}`},
+		{"traits reference", []byte{amf3.Array, 0x05, 0x01, amf3.Object, 0x13, 0x07, 'F', 'o', 'o', 0x03, 'a', amf3.Integer, 0x01, amf3.Object, 0x01, amf3.Integer, 0x02}, `[{"a":1},{"a":2}]`},
+		{"dense array", []byte{amf3.Array, 0x05, 0x01, amf3.Integer, 0x01, amf3.Null}, `[1,null]`},
+		{"associative array", []byte{amf3.Array, 0x01, 0x03, 'a', amf3.True, 0x01}, `{"a":true}`},
+		// The dense elements are rendered by their index after the associative part
+		{"mixed array", []byte{amf3.Array, 0x05, 0x03, 'a', amf3.True, 0x01, amf3.Null, amf3.Integer, 0x02}, `{"a":true,"0":null,"1":2}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, bytesRead, err := amf3.New(bytes.NewReader(test.data)).Parse()
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if bytesRead != len(test.data) {
+				t.Fatalf("read %d bytes, expected %d", bytesRead, len(test.data))
+			}
+			encoded, err := value.MarshalJSON()
+			if err != nil {
+				t.Fatalf("MarshalJSON failed: %v", err)
+			}
+			if string(encoded) != test.expected {
+				t.Fatalf("got %s, expected %s", encoded, test.expected)
+			}
+		})
+	}
+}
+
+func TestParseTraits(t *testing.T) {
+	// A sealed object of the class Foo and an object referencing it's traits,
+	// then a dynamic anonymous object referencing the member name of the traits, the second string
+	data := []byte{amf3.Array, 0x07, 0x01,
+		amf3.Object, 0x13, 0x07, 'F', 'o', 'o', 0x03, 'a', amf3.Integer, 0x01,
+		amf3.Object, 0x01, amf3.Integer, 0x02,
+		amf3.Object, 0x0B, 0x01, 0x02, amf3.Integer, 0x03, 0x01}
+	value, _, err := amf3.New(bytes.NewReader(data)).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	objects := value.Value.(*amf3.ArrayValue).Dense
+	for i, className := range []string{"Foo", "Foo", ""} {
+		properties, ok := objects[i].Value.([]*amf3.Value)
+		if objects[i].ClassName != className || !ok || len(properties) != 1 || properties[0].Name != "a" || properties[0].Value != int32(i+1) {
+			t.Fatalf("object %d is %q with %v, expected %q with a: %d", i, objects[i].ClassName, objects[i].Value, className, i+1)
+		}
+	}
+}
+
+func TestParseArrayParts(t *testing.T) {
+	data := []byte{amf3.Array, 0x05, 0x03, 'a', amf3.True, 0x01, amf3.Null, amf3.Integer, 0x02}
+	value, _, err := amf3.New(bytes.NewReader(data)).Parse()
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	array, ok := value.Value.(*amf3.ArrayValue)
+	if !ok || len(array.Associative) != 1 || len(array.Dense) != 2 {
+		t.Fatalf("got %#v, expected an associative and two dense elements", value.Value)
+	}
+	if array.Associative[0].Name != "a" || array.Associative[0].Value != true {
+		t.Fatalf("got the associative element %q: %v, expected a: true", array.Associative[0].Name, array.Associative[0].Value)
+	}
+	if array.Dense[0].Marker != amf3.Null || array.Dense[1].Value != int32(2) {
+		t.Fatalf("got the dense elements %v and %v, expected null and 2", array.Dense[0].Value, array.Dense[1].Value)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"externalizable", []byte{amf3.Object, 0x07, 0x07, 'F', 'o', 'o'}, `externalizable object "Foo" is not supported`},
+		{"string reference", []byte{amf3.String, 0x00}, "string reference index"},
+		{"object reference", []byte{amf3.Array, 0x00}, "reference index"},
+		{"traits reference", []byte{amf3.Object, 0x01}, "traits reference index"},
+		{"vector", []byte{amf3.VectorInt, 0x01}, "unsupported type"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := amf3.New(bytes.NewReader(test.data)).Parse()
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got error %v, expected %q", err, test.err)
+			}
+		})
+	}
+}
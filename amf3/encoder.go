@@ -0,0 +1,294 @@
+package amf3
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// The range of the Integers, U29 is signed 29 bit for them
+const (
+	minInteger = -1 << 28
+	maxInteger = 1<<28 - 1
+	maxU29     = 1<<29 - 1
+)
+
+// Encoder writes Value trees in the AMF3 format. The reference tables are kept between the calls,
+// like by the parser. Strings are written once and referenced after that. When the same *Value is encoded again,
+// a reference is written instead of it, which also allows encoding trees with cycles. The values sharing the
+// content, like the ones read by a reference, are the same value for this.
+// An Object is written with dynamic members only, the traits are referenced by the objects of the same class.
+// Integers out of the 29 bit range are written as Doubles.
+type Encoder struct {
+	writer       io.Writer
+	bytesWritten int
+	strings      map[string]int
+	objects      map[interface{}]int
+	// The amount of values in the object table
+	objectCount int
+	traits      map[string]int
+}
+
+func NewEncoder(writer io.Writer) *Encoder {
+	return &Encoder{
+		writer:  writer,
+		strings: make(map[string]int),
+		objects: make(map[interface{}]int),
+		traits:  make(map[string]int),
+	}
+}
+
+// Encode writes the value with it's marker.
+func (e *Encoder) Encode(value *Value) error {
+	if err := e.encodeValue(value); err != nil {
+		return fmt.Errorf("amf3 offset %d: %w", e.bytesWritten, err)
+	}
+	return nil
+}
+
+func (e *Encoder) encodeValue(value *Value) error {
+	if value == nil {
+		return e.writeBytes([]byte{Null})
+	}
+	switch value.Marker {
+	case Undefined, Null:
+		return e.writeBytes([]byte{byte(value.Marker)})
+	case False, True:
+		b, ok := value.Value.(bool)
+		if !ok {
+			return invalidValue(value)
+		}
+		if b {
+			return e.writeBytes([]byte{True})
+		}
+		return e.writeBytes([]byte{False})
+	case Integer:
+		integer, ok := value.Value.(int32)
+		if !ok {
+			return invalidValue(value)
+		}
+		if integer < minInteger || integer > maxInteger {
+			if err := e.writeBytes([]byte{Double}); err != nil {
+				return err
+			}
+			return e.writeDouble(float64(integer))
+		}
+		if err := e.writeBytes([]byte{Integer}); err != nil {
+			return err
+		}
+		return e.writeU29(uint32(integer) & maxU29)
+	case Double:
+		number, ok := value.Value.(float64)
+		if !ok {
+			return invalidValue(value)
+		}
+		if err := e.writeBytes([]byte{Double}); err != nil {
+			return err
+		}
+		return e.writeDouble(number)
+	case String:
+		str, ok := value.Value.(string)
+		if !ok {
+			return invalidValue(value)
+		}
+		if err := e.writeBytes([]byte{String}); err != nil {
+			return err
+		}
+		return e.writeString(str)
+	}
+	// The rest of the types are in the object table
+	if err := e.writeBytes([]byte{byte(value.Marker)}); err != nil {
+		return err
+	}
+	key := identity(value)
+	if index, ok := e.objects[key]; ok {
+		return e.writeU29(uint32(index) << 1)
+	}
+	switch value.Marker {
+	case XmlDocument, Xml, Date, Array, Object, ByteArray:
+	case VectorInt, VectorUint, VectorDouble, VectorObject, Dictionary:
+		return fmt.Errorf("unsupported type %d", value.Marker)
+	default:
+		return fmt.Errorf("unknown type %d", value.Marker)
+	}
+	e.objects[key] = e.objectCount
+	e.objectCount++
+	switch value.Marker {
+	case XmlDocument, Xml:
+		str, ok := value.Value.(string)
+		if !ok {
+			return invalidValue(value)
+		}
+		return e.writeInline(str)
+	case Date:
+		t, ok := value.Value.(time.Time)
+		if !ok {
+			return invalidValue(value)
+		}
+		if err := e.writeU29(1); err != nil {
+			return err
+		}
+		return e.writeDouble(timeToMillis(t))
+	case Array:
+		array, ok := value.Value.(*ArrayValue)
+		if !ok && value.Value != nil {
+			return invalidValue(value)
+		}
+		if array == nil {
+			array = &ArrayValue{}
+		}
+		if len(array.Dense) > maxU29>>1 {
+			return fmt.Errorf("array of length %d does not fit into a U29", len(array.Dense))
+		}
+		if err := e.writeU29(uint32(len(array.Dense))<<1 | 1); err != nil {
+			return err
+		}
+		if err := e.encodeMembers(array.Associative); err != nil {
+			return err
+		}
+		for _, element := range array.Dense {
+			if err := e.encodeValue(element); err != nil {
+				return err
+			}
+		}
+		return nil
+	case Object:
+		properties, ok := value.Value.([]*Value)
+		if !ok && value.Value != nil {
+			return invalidValue(value)
+		}
+		if index, ok := e.traits[value.ClassName]; ok {
+			if err := e.writeU29(uint32(index)<<2 | 1); err != nil {
+				return err
+			}
+		} else {
+			e.traits[value.ClassName] = len(e.traits)
+			// Dynamic, no sealed members, inline traits and object
+			if err := e.writeU29(0x0B); err != nil {
+				return err
+			}
+			if err := e.writeString(value.ClassName); err != nil {
+				return err
+			}
+		}
+		return e.encodeMembers(properties)
+	default:
+		data, ok := value.Value.([]byte)
+		if !ok && value.Value != nil {
+			return invalidValue(value)
+		}
+		if len(data) > maxU29>>1 {
+			return fmt.Errorf("byte array of length %d does not fit into a U29", len(data))
+		}
+		if err := e.writeU29(uint32(len(data))<<1 | 1); err != nil {
+			return err
+		}
+		return e.writeBytes(data)
+	}
+}
+
+// identity returns the key of the value in the object table. The parser resolves a reference to a new *Value
+// with the same content, so the content is the identity of the arrays, objects and byte arrays.
+func identity(value *Value) interface{} {
+	switch v := value.Value.(type) {
+	case *ArrayValue:
+		return v
+	case []*Value:
+		if len(v) > 0 {
+			return sliceIdentity{pointer: &v[0], length: len(v)}
+		}
+	case []byte:
+		if len(v) > 0 {
+			return bytesIdentity{pointer: &v[0], length: len(v)}
+		}
+	}
+	return value
+}
+
+type sliceIdentity struct {
+	pointer **Value
+	length  int
+}
+
+type bytesIdentity struct {
+	pointer *byte
+	length  int
+}
+
+// encodeMembers writes the named values terminated by an empty string.
+func (e *Encoder) encodeMembers(values []*Value) error {
+	for _, value := range values {
+		if value == nil || value.Name == "" {
+			return errors.New("member name can not be empty, it would be read as the end of the members")
+		}
+		if err := e.writeString(value.Name); err != nil {
+			return err
+		}
+		if err := e.encodeValue(value); err != nil {
+			return err
+		}
+	}
+	return e.writeString("")
+}
+
+// writeU29 writes the variable length unsigned 29 bit integer, see readU29.
+func (e *Encoder) writeU29(u uint32) error {
+	switch {
+	case u < 0x80:
+		return e.writeBytes([]byte{byte(u)})
+	case u < 0x4000:
+		return e.writeBytes([]byte{byte(u>>7) | 0x80, byte(u & 0x7F)})
+	case u < 0x200000:
+		return e.writeBytes([]byte{byte(u>>14) | 0x80, byte(u>>7) | 0x80, byte(u & 0x7F)})
+	case u <= maxU29:
+		return e.writeBytes([]byte{byte(u>>22) | 0x80, byte(u>>15) | 0x80, byte(u>>8) | 0x80, byte(u)})
+	default:
+		return fmt.Errorf("%d does not fit into a U29", u)
+	}
+}
+
+// writeString writes a string by reference, if it was written before. Empty strings are never sent by reference.
+func (e *Encoder) writeString(str string) error {
+	if index, ok := e.strings[str]; ok {
+		return e.writeU29(uint32(index) << 1)
+	}
+	if str != "" {
+		e.strings[str] = len(e.strings)
+	}
+	return e.writeInline(str)
+}
+
+// writeInline writes the length and the bytes of the string.
+func (e *Encoder) writeInline(str string) error {
+	if len(str) > maxU29>>1 {
+		return fmt.Errorf("string of length %d does not fit into a U29", len(str))
+	}
+	if err := e.writeU29(uint32(len(str))<<1 | 1); err != nil {
+		return err
+	}
+	return e.writeBytes([]byte(str))
+}
+
+func (e *Encoder) writeDouble(value float64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, math.Float64bits(value))
+	return e.writeBytes(data)
+}
+
+func (e *Encoder) writeBytes(data []byte) error {
+	n, err := e.writer.Write(data)
+	e.bytesWritten += n
+	return err
+}
+
+func invalidValue(value *Value) error {
+	return fmt.Errorf("invalid value of type %T for type %d", value.Value, value.Marker)
+}
+
+// timeToMillis converts the time to milliseconds since epoch, precision below milliseconds is kept as fraction.
+func timeToMillis(t time.Time) float64 {
+	return float64(t.Unix())*1000 + float64(t.Nanosecond())/float64(time.Millisecond)
+}
@@ -0,0 +1,31 @@
+package amf3_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/balazshorvath/goamf/amf3"
+)
+
+func FuzzParse(f *testing.F) {
+	f.Add([]byte{amf3.Integer, 0x7F})
+	f.Add([]byte{amf3.Double, 0x40, 0x09, 0x1E, 0xB8, 0x51, 0xEB, 0x85, 0x1F})
+	f.Add([]byte{amf3.String, 0x07, 'f', 'o', 'o'})
+	f.Add([]byte{amf3.Date, 0x01, 0, 0, 0, 0, 0, 0, 0, 0})
+	f.Add([]byte{amf3.Array, 0x05, 0x03, 'a', amf3.True, 0x01, amf3.Null, amf3.Array, 0x00})
+	f.Add([]byte{amf3.Object, 0x0B, 0x01, 0x05, 'i', 'd', amf3.Integer, 0x01, 0x09, 's', 'e', 'l', 'f', amf3.Object, 0x00, 0x01})
+	f.Add([]byte{amf3.ByteArray, 0x05, 0x01, 0x02})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		value, _, err := amf3.New(bytes.NewReader(data)).Parse()
+		if err != nil {
+			return
+		}
+		var buffer bytes.Buffer
+		if err := amf3.NewEncoder(&buffer).Encode(value); err != nil {
+			return
+		}
+		if _, _, err := amf3.New(bytes.NewReader(buffer.Bytes())).Parse(); err != nil {
+			t.Fatalf("parsing the encoded % x failed: %v", buffer.Bytes(), err)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package amf3
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// MarshalJSON renders the value for debugging purposes.
+// Objects become JSON objects with the properties in order, Dates RFC3339 strings,
+// ByteArrays base64 strings, Null and Undefined null.
+// Arrays without an associative part become JSON arrays, otherwise JSON objects,
+// where the dense elements are keyed by their index. Arrays and objects appearing again, like the ones
+// read by references, are rendered once, then as {"$ref":"path"} with the path of their first appearance,
+// like "a.b[2]". NaN and infinite Doubles are an error, so are the values containing themselves.
+func (v *Value) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	r := &rendering{ancestors: make(map[interface{}]bool), paths: make(map[interface{}]string)}
+	if err := v.writeJSON(&buffer, "", r); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// rendering holds the arrays and objects of a rendered tree by identity, see identity,
+// the ones containing the value being rendered and the paths of the ones already rendered.
+type rendering struct {
+	ancestors map[interface{}]bool
+	paths     map[interface{}]string
+}
+
+func (v *Value) writeJSON(buffer *bytes.Buffer, path string, r *rendering) error {
+	if v == nil {
+		buffer.WriteString("null")
+		return nil
+	}
+	if v.Marker == Array || v.Marker == Object {
+		key := identity(v)
+		if r.ancestors[key] {
+			return fmt.Errorf("type %d contains itself, JSON can't represent cycles", v.Marker)
+		}
+		if first, ok := r.paths[key]; ok {
+			data, err := json.Marshal(first)
+			if err != nil {
+				return err
+			}
+			buffer.WriteString(`{"$ref":`)
+			buffer.Write(data)
+			buffer.WriteByte('}')
+			return nil
+		}
+		r.ancestors[key] = true
+		r.paths[key] = path
+		defer delete(r.ancestors, key)
+	}
+	switch v.Marker {
+	case Undefined, Null:
+		buffer.WriteString("null")
+	case False, True, Integer, Double, String, XmlDocument, Xml, ByteArray:
+		if number, ok := v.Value.(float64); ok && (math.IsNaN(number) || math.IsInf(number, 0)) {
+			return fmt.Errorf("double %v can not be represented in JSON", number)
+		}
+		data, err := json.Marshal(v.Value)
+		if err != nil {
+			return err
+		}
+		buffer.Write(data)
+	case Date:
+		t, _ := v.Value.(time.Time)
+		data, err := json.Marshal(t.Format(time.RFC3339Nano))
+		if err != nil {
+			return err
+		}
+		buffer.Write(data)
+	case Array:
+		array, _ := v.Value.(*ArrayValue)
+		if array == nil {
+			array = &ArrayValue{}
+		}
+		if len(array.Associative) == 0 {
+			buffer.WriteByte('[')
+			for i, element := range array.Dense {
+				if i > 0 {
+					buffer.WriteByte(',')
+				}
+				if err := element.writeJSON(buffer, path+"["+strconv.Itoa(i)+"]", r); err != nil {
+					return err
+				}
+			}
+			buffer.WriteByte(']')
+			return nil
+		}
+		properties := append([]*Value(nil), array.Associative...)
+		for i, element := range array.Dense {
+			properties = append(properties, &Value{Marker: element.Marker, Name: strconv.Itoa(i), Value: element.Value})
+		}
+		return writeJSONObject(buffer, properties, path, r)
+	case Object:
+		properties, _ := v.Value.([]*Value)
+		return writeJSONObject(buffer, properties, path, r)
+	default:
+		return fmt.Errorf("unsupported type %d", v.Marker)
+	}
+	return nil
+}
+
+func writeJSONObject(buffer *bytes.Buffer, properties []*Value, path string, r *rendering) error {
+	buffer.WriteByte('{')
+	for i, property := range properties {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		name, err := json.Marshal(property.Name)
+		if err != nil {
+			return err
+		}
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		propertyPath := property.Name
+		if path != "" {
+			propertyPath = path + "." + property.Name
+		}
+		if err := property.writeJSON(buffer, propertyPath, r); err != nil {
+			return err
+		}
+	}
+	buffer.WriteByte('}')
+	return nil
+}
@@ -0,0 +1,15 @@
+package amf3
+
+// NewByteArray returns a ByteArray with the data.
+func NewByteArray(data []byte) *Value {
+	return &Value{Marker: ByteArray, Value: data}
+}
+
+// Bytes returns the value of a ByteArray.
+func (v *Value) Bytes() ([]byte, bool) {
+	if v == nil || v.Marker != ByteArray {
+		return nil, false
+	}
+	data, ok := v.Value.([]byte)
+	return data, ok || v.Value == nil
+}